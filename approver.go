@@ -0,0 +1,333 @@
+package claudecode
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// approverSocketEnv tells the approver executable to serve the permission prompt
+// MCP server, forwarding requests to the parent process over this socket
+const approverSocketEnv = "CLAUDE_CODE_SDK_GO_APPROVER_SOCKET"
+
+// approverServerName is the MCP server name used for the permission prompt tool
+const approverServerName = "sdk_approver"
+
+// approverToolName is the permission prompt tool exposed by the MCP server
+const approverToolName = "approve"
+
+// ServeApprover serves the permission prompt MCP server over stdin and stdout
+// when the CLI launched this process as one, and reports whether it did. The
+// server only forwards requests to the process running the query, so any
+// binary that calls ServeApprover can be the Options.ApproverExecutable. By
+// default that is the querying program itself, which then calls it first
+// thing in main:
+//
+//	func main() {
+//		if claudecode.ServeApprover() {
+//			return
+//		}
+//		...
+//	}
+//
+// When the server fails, ServeApprover exits the process with a non-zero status.
+func ServeApprover() bool {
+	socketPath := os.Getenv(approverSocketEnv)
+	if socketPath == "" {
+		return false
+	}
+	if code := serveApproverMCP(os.Stdin, os.Stdout, socketPath); code != 0 {
+		os.Exit(code)
+	}
+	return true
+}
+
+// ApprovalRequest is a permission request from the CLI for a single tool use
+type ApprovalRequest struct {
+	ToolName  string                 `json:"tool_name"`
+	Input     map[string]interface{} `json:"input"`
+	ToolUseID string                 `json:"tool_use_id,omitempty"`
+}
+
+// ApprovalDecision answers an ApprovalRequest
+type ApprovalDecision struct {
+	// Allow permits the tool use
+	Allow bool
+	// UpdatedInput optionally replaces the tool input when allowing
+	UpdatedInput map[string]interface{}
+	// Message explains a denial to Claude
+	Message string
+}
+
+// Approver decides whether Claude may use a tool. Setting Options.Approver makes
+// the SDK serve a permission prompt MCP server and wire PermissionPromptTool to it.
+// Approve may be called concurrently.
+type Approver interface {
+	Approve(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error)
+}
+
+// ApproverFunc adapts a function to the Approver interface
+type ApproverFunc func(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error)
+
+// Approve calls f(ctx, request)
+func (f ApproverFunc) Approve(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+	return f(ctx, request)
+}
+
+// permissionPromptResult is the response format the CLI expects from a permission prompt tool
+type permissionPromptResult struct {
+	Behavior     string                 `json:"behavior"`
+	UpdatedInput map[string]interface{} `json:"updatedInput,omitempty"`
+	Message      string                 `json:"message,omitempty"`
+}
+
+func newPermissionPromptResult(request ApprovalRequest, decision ApprovalDecision, err error) permissionPromptResult {
+	if err != nil {
+		return permissionPromptResult{Behavior: "deny", Message: fmt.Sprintf("approver failed: %v", err)}
+	}
+	if !decision.Allow {
+		message := decision.Message
+		if message == "" {
+			message = "denied by approver"
+		}
+		return permissionPromptResult{Behavior: "deny", Message: message}
+	}
+	updatedInput := decision.UpdatedInput
+	if updatedInput == nil {
+		updatedInput = request.Input
+	}
+	return permissionPromptResult{Behavior: "allow", UpdatedInput: updatedInput}
+}
+
+// approverBridge listens on a local socket for requests forwarded by the
+// permission prompt MCP server and answers them with the Approver
+type approverBridge struct {
+	ctx      context.Context
+	approver Approver
+	listener net.Listener
+	dir      string
+	wg       sync.WaitGroup
+}
+
+func startApproverBridge(ctx context.Context, approver Approver) (*approverBridge, error) {
+	dir, err := os.MkdirTemp("", "claude-approver")
+	if err != nil {
+		return nil, &ClaudeSDKError{Message: "failed to create approver socket directory", Cause: err}
+	}
+	listener, err := net.Listen("unix", filepath.Join(dir, "approver.sock"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, &ClaudeSDKError{Message: "failed to listen on approver socket", Cause: err}
+	}
+
+	bridge := &approverBridge{ctx: ctx, approver: approver, listener: listener, dir: dir}
+	bridge.wg.Add(1)
+	go bridge.serve()
+	return bridge, nil
+}
+
+func (b *approverBridge) socketPath() string {
+	return b.listener.Addr().String()
+}
+
+func (b *approverBridge) serve() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			defer conn.Close()
+			b.handle(conn)
+		}()
+	}
+}
+
+func (b *approverBridge) handle(conn net.Conn) {
+	var request ApprovalRequest
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		return
+	}
+	decision, err := b.approver.Approve(b.ctx, request)
+	_ = json.NewEncoder(conn).Encode(newPermissionPromptResult(request, decision, err))
+}
+
+func (b *approverBridge) close() {
+	b.listener.Close()
+	b.wg.Wait()
+	os.RemoveAll(b.dir)
+}
+
+// prepareApprover starts the approver bridge when Options.Approver is set and
+// returns options wired to the permission prompt MCP server, plus a cleanup func.
+// It refuses to run inside an approver launch: a program that does not call
+// ServeApprover would otherwise start another approver, and so on recursively.
+func prepareApprover(ctx context.Context, options *Options) (*Options, func(), error) {
+	if options.Approver == nil {
+		return options, func() {}, nil
+	}
+	if os.Getenv(approverSocketEnv) != "" {
+		return nil, nil, &ClaudeSDKError{Message: "approver launched without serving: call ServeApprover at the start of main or set ApproverExecutable"}
+	}
+
+	var executable string
+	if options.ApproverExecutable != nil && *options.ApproverExecutable != "" {
+		executable = *options.ApproverExecutable
+	} else {
+		var err error
+		if executable, err = os.Executable(); err != nil {
+			return nil, nil, &ClaudeSDKError{Message: "failed to locate executable for approver MCP server", Cause: err}
+		}
+	}
+	servers, err := configuredMCPServers(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bridge, err := startApproverBridge(ctx, options.Approver)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	servers[approverServerName] = McpStdioServerConfig{
+		Command: executable,
+		Env:     map[string]string{approverSocketEnv: bridge.socketPath()},
+	}
+	wired := *options
+	wired.MCPConfig = nil
+	wired.MCPServers = servers
+	promptTool := MCP(approverServerName, approverToolName).String()
+	wired.PermissionPromptTool = &promptTool
+	wired.Approver = nil
+
+	return &wired, bridge.close, nil
+}
+
+// serveApproverMCP runs the permission prompt MCP server over stdio, forwarding
+// each tool call to the parent process. It returns the process exit code.
+func serveApproverMCP(stdin io.Reader, stdout io.Writer, socketPath string) int {
+	encoder := json.NewEncoder(stdout)
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var request struct {
+			ID     *json.RawMessage `json:"id"`
+			Method string           `json:"method"`
+			Params json.RawMessage  `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil || request.ID == nil {
+			// Notifications need no response
+			continue
+		}
+
+		response := jsonRPCMessage{JSONRPC: "2.0", ID: request.ID}
+		result, rpcErr := handleApproverRequest(request.Method, request.Params, socketPath)
+		if rpcErr != nil {
+			response.Error = rpcErr
+		} else {
+			data, err := json.Marshal(result)
+			if err != nil {
+				response.Error = &jsonRPCError{Code: -32603, Message: err.Error()}
+			} else {
+				response.Result = data
+			}
+		}
+		if err := encoder.Encode(response); err != nil {
+			return 1
+		}
+	}
+	if scanner.Err() != nil {
+		return 1
+	}
+	return 0
+}
+
+func handleApproverRequest(method string, params json.RawMessage, socketPath string) (interface{}, *jsonRPCError) {
+	switch method {
+	case "initialize":
+		var initParams struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(params, &initParams)
+		version := initParams.ProtocolVersion
+		if version == "" {
+			version = mcpProtocolVersion
+		}
+		return map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": approverServerName, "version": "1.0.0"},
+		}, nil
+
+	case "ping":
+		return map[string]interface{}{}, nil
+
+	case "tools/list":
+		return map[string]interface{}{
+			"tools": []MCPTool{{
+				Name:        approverToolName,
+				Description: "Asks the Go application whether a tool use is permitted",
+				InputSchema: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"tool_name":   map[string]interface{}{"type": "string"},
+						"input":       map[string]interface{}{"type": "object"},
+						"tool_use_id": map[string]interface{}{"type": "string"},
+					},
+					"required": []string{"tool_name", "input"},
+				},
+			}},
+		}, nil
+
+	case "tools/call":
+		var call struct {
+			Name      string          `json:"name"`
+			Arguments ApprovalRequest `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &call); err != nil {
+			return nil, &jsonRPCError{Code: -32602, Message: err.Error()}
+		}
+		if call.Name != approverToolName {
+			return nil, &jsonRPCError{Code: -32602, Message: fmt.Sprintf("unknown tool %s", call.Name)}
+		}
+
+		result, err := forwardApprovalRequest(socketPath, call.Arguments)
+		if err != nil {
+			result = newPermissionPromptResult(call.Arguments, ApprovalDecision{}, err)
+		}
+		text, _ := json.Marshal(result)
+		return map[string]interface{}{
+			"content": []map[string]interface{}{{"type": "text", "text": string(text)}},
+		}, nil
+	}
+
+	return nil, &jsonRPCError{Code: -32601, Message: "method not found"}
+}
+
+// forwardApprovalRequest sends a request to the parent process and waits for its answer
+func forwardApprovalRequest(socketPath string, request ApprovalRequest) (permissionPromptResult, error) {
+	var result permissionPromptResult
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return result, err
+	}
+	if err := json.NewDecoder(conn).Decode(&result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
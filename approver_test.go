@@ -0,0 +1,186 @@
+package claudecode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain lets the test binary serve as the approver MCP server
+func TestMain(m *testing.M) {
+	if ServeApprover() {
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func TestApproverMCPServer(t *testing.T) {
+	var requests []ApprovalRequest
+	approver := ApproverFunc(func(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+		requests = append(requests, request)
+		switch request.ToolName {
+		case ToolRead:
+			return ApprovalDecision{Allow: true}, nil
+		case ToolBash:
+			return ApprovalDecision{Allow: true, UpdatedInput: map[string]interface{}{"command": "echo safe"}}, nil
+		case ToolWrite:
+			return ApprovalDecision{}, errors.New("policy store unavailable")
+		}
+		return ApprovalDecision{Message: "not on the allow list"}, nil
+	})
+
+	ctx := context.Background()
+	options, stop, err := prepareApprover(ctx, &Options{
+		Approver:   approver,
+		MCPServers: map[string]McpServerConfig{"docs": McpHTTPServerConfig{URL: "https://example.com/mcp"}},
+	})
+	if err != nil {
+		t.Fatalf("prepareApprover failed: %v", err)
+	}
+	defer stop()
+
+	if options.PermissionPromptTool == nil || *options.PermissionPromptTool != "mcp__sdk_approver__approve" {
+		t.Fatalf("Expected permission prompt tool to be wired, got %v", options.PermissionPromptTool)
+	}
+	if options.Approver != nil || len(options.MCPServers) != 2 {
+		t.Fatalf("Unexpected wired options: %+v", options)
+	}
+	if err := options.Validate(); err != nil {
+		t.Fatalf("Wired options should be valid: %v", err)
+	}
+
+	// The approver server is this test binary re-executed; check the handshake first
+	reports, err := InspectMCPServers(ctx, options)
+	if err != nil {
+		t.Fatalf("InspectMCPServers failed: %v", err)
+	}
+	approverReport := reports[1]
+	if approverReport.Status != MCPServerStatusConnected {
+		t.Fatalf("Expected approver server to connect, got %+v (%v)", approverReport, approverReport.Err)
+	}
+	if len(approverReport.Tools) != 1 || approverReport.ToolNames()[0] != *options.PermissionPromptTool {
+		t.Errorf("Unexpected approver tools: %v", approverReport.ToolNames())
+	}
+
+	client, err := startMCPClient(ctx, options.MCPServers[approverServerName].(McpStdioServerConfig), options)
+	if err != nil {
+		t.Fatalf("Failed to start approver server: %v", err)
+	}
+	defer client.close()
+	var initResult map[string]interface{}
+	if err := client.call(ctx, "initialize", map[string]interface{}{"protocolVersion": "2024-11-05"}, &initResult); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if initResult["protocolVersion"] != "2024-11-05" {
+		t.Errorf("Expected negotiated protocol version, got %v", initResult["protocolVersion"])
+	}
+
+	tests := []struct {
+		tool     string
+		expected permissionPromptResult
+	}{
+		{ToolRead, permissionPromptResult{Behavior: "allow", UpdatedInput: map[string]interface{}{"file_path": "x"}}},
+		{ToolBash, permissionPromptResult{Behavior: "allow", UpdatedInput: map[string]interface{}{"command": "echo safe"}}},
+		{ToolWrite, permissionPromptResult{Behavior: "deny", Message: "approver failed: policy store unavailable"}},
+		{ToolWebFetch, permissionPromptResult{Behavior: "deny", Message: "not on the allow list"}},
+	}
+	for _, test := range tests {
+		var callResult struct {
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		}
+		err := client.call(ctx, "tools/call", map[string]interface{}{
+			"name":      approverToolName,
+			"arguments": map[string]interface{}{"tool_name": test.tool, "input": map[string]interface{}{"file_path": "x"}},
+		}, &callResult)
+		if err != nil {
+			t.Fatalf("%s: tools/call failed: %v", test.tool, err)
+		}
+		if len(callResult.Content) != 1 || callResult.Content[0].Type != "text" {
+			t.Fatalf("%s: unexpected content %+v", test.tool, callResult.Content)
+		}
+		var result permissionPromptResult
+		if err := json.Unmarshal([]byte(callResult.Content[0].Text), &result); err != nil {
+			t.Fatalf("%s: invalid result JSON: %v", test.tool, err)
+		}
+		expectedJSON, _ := json.Marshal(test.expected)
+		resultJSON, _ := json.Marshal(result)
+		if string(expectedJSON) != string(resultJSON) {
+			t.Errorf("%s: got %s, want %s", test.tool, resultJSON, expectedJSON)
+		}
+	}
+
+	if len(requests) != len(tests) {
+		t.Errorf("Expected %d approver calls, got %d", len(tests), len(requests))
+	}
+}
+
+func TestApproverConflictsWithPermissionPromptTool(t *testing.T) {
+	options := &Options{
+		Approver:             ApproverFunc(func(context.Context, ApprovalRequest) (ApprovalDecision, error) { return ApprovalDecision{}, nil }),
+		PermissionPromptTool: stringPtr("mcp__custom__approve"),
+	}
+	var optionErr *InvalidOptionError
+	if err := options.Validate(); !errors.As(err, &optionErr) || optionErr.Field != "Approver" {
+		t.Errorf("Expected Approver conflict, got %v", err)
+	}
+}
+
+func TestServeApproverWithoutSocket(t *testing.T) {
+	if ServeApprover() {
+		t.Error("Expected ServeApprover to do nothing outside an approver launch")
+	}
+}
+
+func TestApproverMCPConfigRelativeToCwd(t *testing.T) {
+	dir := t.TempDir()
+	config := `{"mcpServers": {"docs": {"type": "http", "url": "https://example.com/mcp"}}}`
+	if err := os.WriteFile(filepath.Join(dir, ".mcp.json"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	executable := "/usr/local/bin/approver"
+	options, stop, err := prepareApprover(context.Background(), &Options{
+		Cwd:                &dir,
+		MCPConfig:          stringPtr(".mcp.json"),
+		Approver:           ApproverFunc(func(context.Context, ApprovalRequest) (ApprovalDecision, error) { return ApprovalDecision{}, nil }),
+		ApproverExecutable: &executable,
+	})
+	if err != nil {
+		t.Fatalf("prepareApprover failed: %v", err)
+	}
+	defer stop()
+	if _, ok := options.MCPServers["docs"]; !ok {
+		t.Errorf("Expected servers from the MCPConfig in Cwd, got %v", options.MCPServers)
+	}
+	if server := options.MCPServers[approverServerName].(McpStdioServerConfig); server.Command != executable {
+		t.Errorf("Expected approver executable %s, got %s", executable, server.Command)
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("stdout closed") }
+
+func TestApproverLaunchWithoutServing(t *testing.T) {
+	t.Setenv(approverSocketEnv, filepath.Join(t.TempDir(), "approver.sock"))
+	_, _, err := prepareApprover(context.Background(), &Options{
+		Approver: ApproverFunc(func(context.Context, ApprovalRequest) (ApprovalDecision, error) { return ApprovalDecision{}, nil }),
+	})
+	var sdkErr *ClaudeSDKError
+	if !errors.As(err, &sdkErr) {
+		t.Errorf("Expected an approver launch not to start another approver, got %v", err)
+	}
+
+	request := `{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n"
+	if code := serveApproverMCP(strings.NewReader(request), failingWriter{}, "unused"); code == 0 {
+		t.Error("Expected a failing server to report a non-zero exit code")
+	}
+}
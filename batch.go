@@ -0,0 +1,303 @@
+package claudecode
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultBatchConcurrency is the number of queries RunBatch runs at once by default
+const defaultBatchConcurrency = 4
+
+// maxBatchLineSize bounds a single request or result line
+const maxBatchLineSize = 16 * 1024 * 1024
+
+// BatchRequest is one line of a batch input file: a QueryRequest with an
+// optional ID. Requests without an ID are identified by their line index.
+type BatchRequest struct {
+	ID string `json:"id,omitempty"`
+	QueryRequest
+}
+
+// BatchResult is one line of batch output
+type BatchResult struct {
+	ID string `json:"id"`
+	// Index is the position of the request among the non-empty input lines
+	Index      int      `json:"index"`
+	Prompt     string   `json:"prompt,omitempty"`
+	SessionID  string   `json:"session_id,omitempty"`
+	Result     *string  `json:"result,omitempty"`
+	IsError    bool     `json:"is_error"`
+	Usage      *Usage   `json:"usage,omitempty"`
+	CostUSD    *float64 `json:"cost_usd,omitempty"`
+	DurationMs int      `json:"duration_ms,omitempty"`
+	// Messages are the messages of the query, encoded with MarshalMessage
+	Messages []json.RawMessage `json:"messages,omitempty"`
+	// Error is set when the request could not be parsed or the query failed
+	Error string `json:"error,omitempty"`
+}
+
+// DecodeMessages parses the recorded messages
+func (r *BatchResult) DecodeMessages() ([]Message, error) {
+	messages := make([]Message, 0, len(r.Messages))
+	for _, data := range r.Messages {
+		message, err := UnmarshalMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// BatchOptions configures RunBatch
+type BatchOptions struct {
+	// Concurrency bounds the number of queries running at once; defaults to 4
+	Concurrency int
+	// Ordered writes results in input order; otherwise they are written as they complete
+	Ordered bool
+	// Options are used for requests that do not carry their own options
+	Options *Options
+	// Skip lists request IDs to leave out, e.g. from ReadBatchResultIDs when resuming
+	Skip map[string]bool
+	// OmitMessages leaves BatchResult.Messages empty
+	OmitMessages bool
+}
+
+// BatchStats summarizes a batch run
+type BatchStats struct {
+	Total     int `json:"total"`
+	Skipped   int `json:"skipped"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// batchJob is a request waiting to run; seq orders the jobs that are not skipped
+type batchJob struct {
+	seq      int
+	index    int
+	id       string
+	request  QueryRequest
+	parseErr error
+}
+
+type batchOutcome struct {
+	seq    int
+	result BatchResult
+}
+
+// RunBatch reads one BatchRequest per line from reader, runs the queries with
+// bounded concurrency and writes one BatchResult per line to writer. Failed
+// queries are recorded in their result rather than stopping the batch; the
+// returned error reports read and write failures or cancellation. Requests
+// cancelled with ctx are not written, so a later run with Skip set from the
+// output picks them up again.
+func RunBatch(ctx context.Context, reader io.Reader, writer io.Writer, options BatchOptions) (*BatchStats, error) {
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stats := &BatchStats{}
+	jobs := make(chan batchJob)
+	outcomes := make(chan batchOutcome)
+
+	// Read requests
+	var readErr error
+	go func() {
+		defer close(jobs)
+		readErr = readBatchRequests(ctx, reader, options.Skip, stats, jobs)
+	}()
+
+	// Run queries
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				result := runBatchJob(ctx, job, &options)
+				if ctx.Err() != nil && result.Error != "" {
+					// Interrupted; leave the request for a resumed run
+					continue
+				}
+				outcomes <- batchOutcome{seq: job.seq, result: result}
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(outcomes)
+	}()
+
+	// Write results
+	encoder := json.NewEncoder(writer)
+	var writeErr error
+	pending := make(map[int]BatchResult)
+	next := 0
+	write := func(result BatchResult) {
+		if writeErr != nil {
+			return
+		}
+		if err := encoder.Encode(result); err != nil {
+			writeErr = err
+			cancel()
+			return
+		}
+		if result.Error != "" || result.IsError {
+			stats.Failed++
+		} else {
+			stats.Succeeded++
+		}
+	}
+	for outcome := range outcomes {
+		if !options.Ordered {
+			write(outcome.result)
+			continue
+		}
+		pending[outcome.seq] = outcome.result
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			write(result)
+			next++
+		}
+	}
+	if options.Ordered && ctx.Err() != nil {
+		// Results queued behind an interrupted request are still complete
+		for seq := next; len(pending) > 0; seq++ {
+			if result, ok := pending[seq]; ok {
+				delete(pending, seq)
+				write(result)
+			}
+		}
+	}
+
+	switch {
+	case writeErr != nil:
+		return stats, &ClaudeSDKError{Message: "failed to write batch result", Cause: writeErr}
+	case readErr != nil:
+		return stats, readErr
+	}
+	return stats, ctx.Err()
+}
+
+func readBatchRequests(ctx context.Context, reader io.Reader, skip map[string]bool, stats *BatchStats, jobs chan<- batchJob) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBatchLineSize)
+	index, seq := 0, 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		job := batchJob{index: index, id: strconv.Itoa(index)}
+		index++
+		var request BatchRequest
+		if err := json.Unmarshal([]byte(line), &request); err != nil {
+			job.parseErr = err
+		} else {
+			job.request = request.QueryRequest
+			if request.ID != "" {
+				job.id = request.ID
+			}
+		}
+
+		stats.Total++
+		if skip[job.id] {
+			stats.Skipped++
+			continue
+		}
+		job.seq = seq
+		seq++
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return &ClaudeSDKError{Message: "failed to read batch requests", Cause: err}
+	}
+	return nil
+}
+
+func runBatchJob(ctx context.Context, job batchJob, options *BatchOptions) BatchResult {
+	result := BatchResult{ID: job.id, Index: job.index, Prompt: job.request.Prompt}
+	if job.parseErr != nil {
+		result.Error = fmt.Sprintf("invalid request: %v", job.parseErr)
+		return result
+	}
+
+	queryOptions := job.request.Options
+	if queryOptions == nil {
+		queryOptions = options.Options
+	}
+	messages, err := Query(ctx, job.request.Prompt, queryOptions)
+	if !options.OmitMessages {
+		for _, message := range messages {
+			if data, err := MarshalMessage(message); err == nil {
+				result.Messages = append(result.Messages, data)
+			}
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	if final := lastResultMessage(messages); final != nil {
+		result.SessionID = final.SessionID
+		result.Result = final.Result
+		result.IsError = final.IsError
+		result.Usage = final.Usage
+		result.CostUSD = final.TotalCostUSD
+		result.DurationMs = final.DurationMs
+	} else {
+		result.Error = "no result message received"
+	}
+	return result
+}
+
+// ReadBatchResultIDs returns the IDs of the successful results in a previous
+// batch output, for use as BatchOptions.Skip when resuming an interrupted run.
+// Failed requests are not included, so they are retried.
+func ReadBatchResultIDs(reader io.Reader) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBatchLineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var result struct {
+			ID      string `json:"id"`
+			IsError bool   `json:"is_error"`
+			Error   string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			// A line cut short by an interrupted write
+			continue
+		}
+		if result.Error == "" && !result.IsError {
+			ids[result.ID] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ClaudeSDKError{Message: "failed to read batch results", Cause: err}
+	}
+	return ids, nil
+}
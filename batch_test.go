@@ -0,0 +1,140 @@
+package claudecode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const batchFakeCLI = `
+prompt=$(cat)
+case "$prompt" in
+slow) sleep 0.3 ;;
+fail) echo "boom" >&2; exit 1 ;;
+esac
+echo '{"type":"system","subtype":"init","session_id":"s-'"$prompt"'"}'
+echo '{"type":"result","subtype":"success","session_id":"s-'"$prompt"'","total_cost_usd":0.5,"usage":{"input_tokens":1,"output_tokens":2},"result":"done '"$prompt"'"}'
+`
+
+const batchInput = `{"id":"a","prompt":"slow"}
+{"prompt":"fast"}
+
+{"id":"c","prompt":"fail"}
+{"id":"d","prompt":
+`
+
+func decodeBatchResults(t *testing.T, output string) []BatchResult {
+	t.Helper()
+	var results []BatchResult
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var result BatchResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("Invalid result line %q: %v", line, err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func TestRunBatchOrdered(t *testing.T) {
+	cli := writeFakeCLI(t, batchFakeCLI)
+	var out bytes.Buffer
+	stats, err := RunBatch(context.Background(), strings.NewReader(batchInput), &out, BatchOptions{
+		Concurrency: 4,
+		Ordered:     true,
+		Options:     &Options{Executable: &cli},
+	})
+	if err != nil {
+		t.Fatalf("RunBatch failed: %v", err)
+	}
+	if *stats != (BatchStats{Total: 4, Succeeded: 2, Failed: 2}) {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	results := decodeBatchResults(t, out.String())
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+	ids := []string{results[0].ID, results[1].ID, results[2].ID, results[3].ID}
+	if strings.Join(ids, ",") != "a,1,c,3" {
+		t.Errorf("Expected results in input order, got %v", ids)
+	}
+
+	first := results[0]
+	if first.Result == nil || *first.Result != "done slow" || first.SessionID != "s-slow" {
+		t.Errorf("Unexpected first result: %+v", first)
+	}
+	if first.CostUSD == nil || *first.CostUSD != 0.5 || first.Usage == nil || first.Usage.OutputTokens != 2 {
+		t.Errorf("Expected cost and usage, got %+v", first)
+	}
+	messages, err := first.DecodeMessages()
+	if err != nil || len(messages) != 2 || messages[0].Type() != MessageTypeSystem {
+		t.Errorf("Expected recorded messages, got %v %v", messages, err)
+	}
+	if !strings.Contains(results[2].Error, "boom") {
+		t.Errorf("Expected failed query to record stderr, got %q", results[2].Error)
+	}
+	if !strings.HasPrefix(results[3].Error, "invalid request") {
+		t.Errorf("Expected invalid request error, got %q", results[3].Error)
+	}
+}
+
+func TestRunBatchCompletionOrderAndResume(t *testing.T) {
+	cli := writeFakeCLI(t, batchFakeCLI)
+	var out bytes.Buffer
+	_, err := RunBatch(context.Background(), strings.NewReader(batchInput), &out, BatchOptions{
+		Concurrency:  2,
+		Options:      &Options{Executable: &cli},
+		OmitMessages: true,
+	})
+	if err != nil {
+		t.Fatalf("RunBatch failed: %v", err)
+	}
+	results := decodeBatchResults(t, out.String())
+	if results[len(results)-1].ID != "a" {
+		t.Errorf("Expected the slow request to complete last, got %s", results[len(results)-1].ID)
+	}
+	if len(results[0].Messages) != 0 {
+		t.Error("Expected messages to be omitted")
+	}
+
+	skip, err := ReadBatchResultIDs(strings.NewReader(out.String() + `{"id":"trunc`))
+	if err != nil {
+		t.Fatalf("ReadBatchResultIDs failed: %v", err)
+	}
+	if len(skip) != 2 || !skip["a"] || !skip["1"] {
+		t.Errorf("Expected only successful IDs, got %v", skip)
+	}
+
+	out.Reset()
+	stats, err := RunBatch(context.Background(), strings.NewReader(batchInput), &out, BatchOptions{
+		Options: &Options{Executable: &cli},
+		Skip:    skip,
+		Ordered: true,
+	})
+	if err != nil {
+		t.Fatalf("Resumed RunBatch failed: %v", err)
+	}
+	if stats.Skipped != 2 || stats.Failed != 2 {
+		t.Errorf("Unexpected resumed stats: %+v", stats)
+	}
+	results = decodeBatchResults(t, out.String())
+	if len(results) != 2 || results[0].ID != "c" || results[1].ID != "3" {
+		t.Errorf("Expected only the failed requests to rerun, got %+v", results)
+	}
+}
+
+func TestRunBatchCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var out bytes.Buffer
+	_, err := RunBatch(ctx, strings.NewReader(batchInput), &out, BatchOptions{})
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if strings.Contains(out.String(), `"id":"a"`) {
+		t.Errorf("Expected cancelled requests not to be written, got %s", out.String())
+	}
+}
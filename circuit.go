@@ -0,0 +1,358 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+	healthProbeTimeout      = 10 * time.Second
+)
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets queries through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails queries fast with a CircuitOpenError
+	CircuitOpen
+	// CircuitHalfOpen is probing whether the CLI has recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// CircuitBreakerOptions configures a CircuitBreaker
+type CircuitBreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit (default 5)
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probing the CLI (default 30s)
+	OpenTimeout time.Duration
+	// Probe checks whether the CLI works again before the trial query runs. The
+	// default runs the CLI with --version, which does not check authentication:
+	// bad credentials only show up in the trial query.
+	Probe func(ctx context.Context, options *Options) error
+	// OnStateChange is called after every state change, without holding the breaker's lock
+	OnStateChange func(from, to CircuitState)
+}
+
+// CircuitBreaker stops launching the CLI after repeated failures that point to
+// a broken setup, such as bad authentication or a missing install. Only a
+// *ProcessError (other than a rate limit) or a *CLINotFoundError counts as a
+// failure; a successful query resets the count. While the circuit is open,
+// queries fail at once with a *CircuitOpenError. Once OpenTimeout has passed
+// the next query runs Probe first and, if it succeeds, runs as a trial query
+// while the circuit stays half-open: the circuit closes when the trial query
+// succeeds and reopens when it fails.
+type CircuitBreaker struct {
+	options CircuitBreakerOptions
+	now     func() time.Time
+
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	lastError error
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(options CircuitBreakerOptions) *CircuitBreaker {
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = defaultFailureThreshold
+	}
+	if options.OpenTimeout <= 0 {
+		options.OpenTimeout = defaultOpenTimeout
+	}
+	if options.Probe == nil {
+		options.Probe = probeCLI
+	}
+	return &CircuitBreaker{options: options, now: time.Now}
+}
+
+// State returns the current state of the circuit
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Query runs Query unless the circuit is open
+func (b *CircuitBreaker) Query(ctx context.Context, prompt string, options *Options) ([]Message, error) {
+	trial, err := b.allow(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := Query(ctx, prompt, options)
+	b.record(ctx, trial, err)
+	return messages, err
+}
+
+// QueryStream runs QueryStream unless the circuit is open. The outcome is
+// recorded when the query ends, even if the caller stops reading.
+func (b *CircuitBreaker) QueryStream(ctx context.Context, prompt string, options *Options) (<-chan Message, <-chan error) {
+	messageChan := make(chan Message, 10)
+	errorChan := make(chan error, 1)
+
+	go func() {
+		defer close(messageChan)
+		defer close(errorChan)
+
+		trial, err := b.allow(ctx, options)
+		if err != nil {
+			errorChan <- err
+			return
+		}
+
+		// Read the query to its end independently of the caller, so that a
+		// trial the caller abandons still closes or reopens the circuit
+		queue := newMessageQueue()
+		messages, errs := QueryStream(ctx, prompt, options)
+		go func() {
+			for message := range messages {
+				queue.push(message)
+			}
+			var queryErr error
+			for err := range errs {
+				queryErr = err
+			}
+			b.record(ctx, trial, queryErr)
+			queue.close(queryErr)
+		}()
+
+		for {
+			message, ok := queue.next(ctx)
+			if !ok {
+				break
+			}
+			select {
+			case messageChan <- message:
+			case <-ctx.Done():
+			}
+		}
+		if err := queue.err(ctx); err != nil {
+			errorChan <- err
+		}
+	}()
+
+	return messageChan, errorChan
+}
+
+// messageQueue is an unbounded FIFO of messages ending with an error
+type messageQueue struct {
+	mu       sync.Mutex
+	messages []Message
+	closed   bool
+	queryErr error
+	ready    chan struct{}
+}
+
+func newMessageQueue() *messageQueue {
+	return &messageQueue{ready: make(chan struct{}, 1)}
+}
+
+func (q *messageQueue) push(message Message) {
+	q.mu.Lock()
+	q.messages = append(q.messages, message)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// close ends the queue with the query's error
+func (q *messageQueue) close(err error) {
+	q.mu.Lock()
+	q.closed = true
+	q.queryErr = err
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *messageQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the next message, or false once the queue is closed and
+// empty or ctx is done
+func (q *messageQueue) next(ctx context.Context) (Message, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.messages) > 0 {
+			message := q.messages[0]
+			q.messages[0] = nil
+			q.messages = q.messages[1:]
+			q.mu.Unlock()
+			return message, true
+		}
+		closed := q.closed
+		q.mu.Unlock()
+		if closed {
+			return nil, false
+		}
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// err returns the query's error, or ctx's error when the caller gave up first
+func (q *messageQueue) err(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		return ctx.Err()
+	}
+	return q.queryErr
+}
+
+// allow returns a *CircuitOpenError unless a query may run, probing the CLI
+// when the open timeout has passed. trial reports that the query decides
+// whether the half-open circuit closes.
+func (b *CircuitBreaker) allow(ctx context.Context, options *Options) (trial bool, err error) {
+	b.mu.Lock()
+	switch b.state {
+	case CircuitClosed:
+		b.mu.Unlock()
+		return false, nil
+	case CircuitHalfOpen:
+		// Another query is probing or running as the trial
+		err := b.openError()
+		b.mu.Unlock()
+		return false, err
+	}
+	if b.now().Before(b.openedAt.Add(b.options.OpenTimeout)) {
+		err := b.openError()
+		b.mu.Unlock()
+		return false, err
+	}
+	b.state = CircuitHalfOpen
+	b.mu.Unlock()
+	b.notify(CircuitOpen, CircuitHalfOpen)
+
+	if options == nil {
+		options = &Options{}
+	}
+	probeErr := b.options.Probe(ctx, options)
+
+	b.mu.Lock()
+	if probeErr != nil && ctx.Err() != nil {
+		// The caller gave up; let the next query probe again
+		b.state = CircuitOpen
+		b.mu.Unlock()
+		b.notify(CircuitHalfOpen, CircuitOpen)
+		return false, ctx.Err()
+	}
+	if probeErr != nil {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+		b.lastError = probeErr
+		err := b.openError()
+		b.mu.Unlock()
+		b.notify(CircuitHalfOpen, CircuitOpen)
+		return false, err
+	}
+	b.mu.Unlock()
+	return true, nil
+}
+
+// record counts the outcome of a query that was let through. Queries cut short
+// by their context say nothing about the CLI; when such a query is the trial,
+// the circuit reopens so that the next query probes again.
+func (b *CircuitBreaker) record(ctx context.Context, trial bool, err error) {
+	inconclusive := ctx.Err() != nil || (err != nil && !isCircuitFailure(err))
+
+	b.mu.Lock()
+	from := b.state
+	switch {
+	case trial && inconclusive:
+		b.state = CircuitOpen
+	case trial && err == nil:
+		b.state = CircuitClosed
+		b.failures = 0
+	case trial:
+		// A single failed trial reopens the circuit for another OpenTimeout
+		b.failures++
+		b.lastError = err
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	case inconclusive:
+	case err == nil:
+		b.failures = 0
+	default:
+		b.failures++
+		b.lastError = err
+		if b.state == CircuitClosed && b.failures >= b.options.FailureThreshold {
+			b.state = CircuitOpen
+			b.openedAt = b.now()
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	if from != to {
+		b.notify(from, to)
+	}
+}
+
+// openError describes the open circuit. The caller holds b.mu.
+func (b *CircuitBreaker) openError() *CircuitOpenError {
+	return &CircuitOpenError{
+		Failures:  b.failures,
+		RetryAt:   b.openedAt.Add(b.options.OpenTimeout),
+		LastError: b.lastError,
+	}
+}
+
+func (b *CircuitBreaker) notify(from, to CircuitState) {
+	if b.options.OnStateChange != nil {
+		b.options.OnStateChange(from, to)
+	}
+}
+
+// isCircuitFailure reports whether err points to a broken CLI setup
+func isCircuitFailure(err error) bool {
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return false
+	}
+	var processErr *ProcessError
+	var notFoundErr *CLINotFoundError
+	return errors.As(err, &processErr) || errors.As(err, &notFoundErr)
+}
+
+// probeCLI is the default health probe: the CLI must run and exit cleanly with --version
+func probeCLI(ctx context.Context, options *Options) error {
+	cliPath, err := findCLIExecutable(options.Executable)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, cliPath, "--version").CombinedOutput()
+	if err != nil {
+		exitCode := -1
+		if exitError, ok := err.(*exec.ExitError); ok {
+			exitCode = exitError.ExitCode()
+		}
+		return &ProcessError{ExitCode: exitCode, Stderr: strings.TrimSpace(string(output))}
+	}
+	return nil
+}
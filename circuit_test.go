@@ -0,0 +1,198 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	// The fake CLI counts its launches and fails while the marker file exists
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken")
+	launches := filepath.Join(dir, "launches")
+	if err := os.WriteFile(broken, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cli := writeFakeCLI(t, `
+echo x >> `+launches+`
+if [ -f `+broken+` ]; then echo "Invalid API key" >&2; exit 1; fi
+`+batchFakeCLI)
+	options := &Options{Executable: &cli}
+	countLaunches := func() int {
+		data, _ := os.ReadFile(launches)
+		return strings.Count(string(data), "x")
+	}
+
+	var transitions []string
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	breaker := NewCircuitBreaker(CircuitBreakerOptions{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		OnStateChange: func(from, to CircuitState) {
+			transitions = append(transitions, from.String()+">"+to.String())
+		},
+	})
+	breaker.now = clock.Now
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		var processErr *ProcessError
+		if _, err := breaker.Query(ctx, "fast", options); !errors.As(err, &processErr) {
+			t.Fatalf("Expected a process error, got %v", err)
+		}
+	}
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("Expected the circuit to open, got %s", breaker.State())
+	}
+
+	_, err := breaker.Query(ctx, "fast", options)
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || openErr.Failures != 2 || !strings.Contains(openErr.Error(), "Invalid API key") {
+		t.Errorf("Expected a circuit open error, got %v", err)
+	}
+	_, errs := breaker.QueryStream(ctx, "fast", options)
+	if err := <-errs; !errors.As(err, &openErr) {
+		t.Errorf("Expected the stream to fail fast, got %v", err)
+	}
+	if countLaunches() != 2 {
+		t.Errorf("Expected no launches while open, got %d", countLaunches())
+	}
+
+	// The probe still fails, so the circuit stays open for another timeout
+	clock.Advance(time.Minute)
+	if _, err := breaker.Query(ctx, "fast", options); !errors.As(err, &openErr) || !openErr.RetryAt.Equal(clock.now.Add(time.Minute)) {
+		t.Errorf("Expected a failed probe to reopen the circuit, got %v", err)
+	}
+
+	if err := os.Remove(broken); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	if _, err := breaker.Query(ctx, "fast", options); err != nil {
+		t.Errorf("Expected the query to run after a successful probe, got %v", err)
+	}
+	if breaker.State() != CircuitClosed {
+		t.Errorf("Expected the circuit to close, got %s", breaker.State())
+	}
+
+	expected := "closed>open open>half-open half-open>open open>half-open half-open>closed"
+	if strings.Join(transitions, " ") != expected {
+		t.Errorf("Expected transitions %q, got %q", expected, strings.Join(transitions, " "))
+	}
+}
+
+func TestCircuitBreakerIgnoresOtherErrors(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerOptions{FailureThreshold: 1})
+	breaker.record(context.Background(), false, &RateLimitError{Cause: &ProcessError{ExitCode: 1}})
+	breaker.record(context.Background(), false, &StallError{Kind: StallKindIdle})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	breaker.record(ctx, false, &ProcessError{ExitCode: -1})
+	if breaker.State() != CircuitClosed {
+		t.Errorf("Expected rate limits, stalls and cancellation not to open the circuit")
+	}
+
+	missing := "/nonexistent/claude"
+	if _, err := breaker.Query(context.Background(), "hi", &Options{Executable: &missing}); err == nil {
+		t.Fatal("Expected an error for a missing CLI")
+	}
+	if breaker.State() != CircuitOpen {
+		t.Errorf("Expected a missing CLI to open the circuit")
+	}
+}
+
+func TestCircuitBreakerTrialQuery(t *testing.T) {
+	// The probe passes, as --version does with bad credentials, but queries fail
+	cli := writeFakeCLI(t, `cat >/dev/null; echo "Invalid API key" >&2; exit 1`)
+	options := &Options{Executable: &cli}
+	var transitions []string
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	breaker := NewCircuitBreaker(CircuitBreakerOptions{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		Probe:            func(ctx context.Context, options *Options) error { return nil },
+		OnStateChange: func(from, to CircuitState) {
+			transitions = append(transitions, from.String()+">"+to.String())
+		},
+	})
+	breaker.now = clock.Now
+	ctx := context.Background()
+
+	breaker.record(ctx, false, &ProcessError{ExitCode: 1})
+	breaker.record(ctx, false, &ProcessError{ExitCode: 1})
+	clock.Advance(time.Minute)
+
+	var processErr *ProcessError
+	if _, err := breaker.Query(ctx, "fast", options); !errors.As(err, &processErr) {
+		t.Fatalf("Expected the trial query to run and fail, got %v", err)
+	}
+	_, err := breaker.Query(ctx, "fast", options)
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || !openErr.RetryAt.Equal(clock.now.Add(time.Minute)) {
+		t.Errorf("Expected a single failed trial to reopen the circuit, got %v", err)
+	}
+
+	// A canceled trial is inconclusive: the next query probes again
+	clock.Advance(time.Minute)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	trial, err := breaker.allow(ctx, options)
+	if !trial || err != nil {
+		t.Fatalf("Expected a trial query, got %v %v", trial, err)
+	}
+	if _, err := breaker.allow(ctx, options); !errors.As(err, &openErr) {
+		t.Errorf("Expected queries to wait for the trial, got %v", err)
+	}
+	breaker.record(canceled, true, canceled.Err())
+	if trial, err := breaker.allow(ctx, options); !trial || err != nil {
+		t.Errorf("Expected another trial after a canceled one, got %v %v", trial, err)
+	}
+	breaker.record(ctx, true, nil)
+	if breaker.State() != CircuitClosed {
+		t.Errorf("Expected a successful trial to close the circuit, got %s", breaker.State())
+	}
+
+	expected := "closed>open open>half-open half-open>open open>half-open half-open>open open>half-open half-open>closed"
+	if strings.Join(transitions, " ") != expected {
+		t.Errorf("Expected transitions %q, got %q", expected, strings.Join(transitions, " "))
+	}
+}
+
+func TestCircuitBreakerAbandonedTrialStream(t *testing.T) {
+	cli := writeFakeCLI(t, `
+cat >/dev/null
+i=0
+while [ $i -lt 50 ]; do
+  echo '{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"chunk"}]}}'
+  i=$((i+1))
+done
+echo '{"type":"result","subtype":"success","result":"done"}'
+`)
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	breaker := NewCircuitBreaker(CircuitBreakerOptions{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Minute,
+		Probe:            func(ctx context.Context, options *Options) error { return nil },
+	})
+	breaker.now = clock.Now
+	breaker.record(context.Background(), false, &ProcessError{ExitCode: 1})
+	clock.Advance(time.Minute)
+
+	// The caller reads one message of the trial and walks away without canceling
+	messages, _ := breaker.QueryStream(context.Background(), "hi", &Options{Executable: &cli})
+	<-messages
+
+	deadline := time.Now().Add(2 * time.Second)
+	for breaker.State() != CircuitClosed {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the abandoned trial to close the circuit, got %s", breaker.State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
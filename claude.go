@@ -1,9 +1,9 @@
 package claudecode
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -18,11 +18,34 @@ func QueryWithRequest(ctx context.Context, request QueryRequest) ([]Message, err
 	return Query(ctx, request.Prompt, request.Options)
 }
 
-// Query executes a query against Claude Code and returns the messages
+// Query executes a query against Claude Code and returns the messages. Messages
+// are collected as they are read, so a query that fails part way returns the
+// messages read so far together with the error.
 func Query(ctx context.Context, prompt string, options *Options) ([]Message, error) {
 	if options == nil {
 		options = &Options{}
 	}
+	var messages []Message
+	err := chainInterceptors(options.Interceptors, queryHandler)(ctx, prompt, options, func(message Message) error {
+		messages = append(messages, message)
+		return nil
+	})
+	return messages, err
+}
+
+// runQuery runs the CLI once with validated options, passing each message to
+// emit as soon as it is read, and returns the messages read
+func runQuery(ctx context.Context, prompt string, options *Options, emit func(Message) error) ([]Message, error) {
+	options, stopApprover, err := prepareApprover(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	defer stopApprover()
+	options, removeMCPConfig, err := prepareMCPServers(options)
+	if err != nil {
+		return nil, err
+	}
+	defer removeMCPConfig()
 
 	// Set environment variable to identify SDK
 	os.Setenv("CLAUDE_CODE_ENTRYPOINT", "sdk-go")
@@ -37,28 +60,58 @@ func Query(ctx context.Context, prompt string, options *Options) ([]Message, err
 		return nil, err
 	}
 
+	log := newQueryLogger(options)
+	log.launching(cmd)
+	notifyProcessStart(ctx)
 	if err := cmd.Start(); err != nil {
+		log.Warn("failed to start CLI", "error", err)
 		return nil, &CLIConnectionError{
 			Message: "failed to start Claude CLI",
 			Cause:   err,
 		}
 	}
+	log = log.startedProcess(cmd)
+	stderrTail := captureStderr(stderr)
 
-	// Send prompt to stdin and close it
-	if _, writeErr := stdin.Write([]byte(prompt)); writeErr != nil {
+	// Send prompt to stdin and close it so the CLI starts processing
+	_, writeErr := stdin.Write([]byte(prompt))
+	stdin.Close()
+	if writeErr != nil {
+		// The CLI closed its stdin, usually because it exited early; its exit
+		// status explains the failure better than the broken pipe
+		_, _ = io.Copy(io.Discard, stdout)
+		if waitErr := waitForCommand(cmd, stderrTail, log); waitErr != nil {
+			return nil, waitErr
+		}
 		return nil, &CLIConnectionError{
 			Message: "failed to write prompt to stdin",
-			Cause:   err,
+			Cause:   writeErr,
 		}
 	}
-	defer stdin.Close()
 
-	messages, err := readOutput(stdout, options)
+	var emitErr error
+	messages, err := readOutput(ctx, stdout, options, stderrTail, log, func(message Message) error {
+		emitErr = emit(message)
+		return emitErr
+	})
+	if emitErr != nil {
+		terminateCommand(cmd, stderrTail, log)
+		return messages, emitErr
+	}
 	if err != nil {
-		return nil, handleReadError(err, stderr)
+		var decodeErr *CLIJSONDecodeError
+		if errors.As(err, &decodeErr) && options.outputFormat() == OutputFormatJSON {
+			// The document was read to the end, so the process is done and its
+			// exit status explains an empty or truncated document best
+			if waitErr := waitForCommand(cmd, stderrTail, log); waitErr != nil {
+				return nil, waitErr
+			}
+			return nil, err
+		}
+		return nil, handleReadError(err, cmd, stderrTail, log)
 	}
 
-	return messages, waitForCommand(cmd, stderr)
+	return messages, waitForCommand(cmd, stderrTail, log)
 }
 
 func setupCommand(ctx context.Context, options *Options) (*exec.Cmd, error) {
@@ -105,48 +158,83 @@ func createPipes(cmd *exec.Cmd) (io.WriteCloser, io.ReadCloser, io.ReadCloser, e
 	return stdin, stdout, stderr, nil
 }
 
-func readOutput(stdout io.ReadCloser, options *Options) ([]Message, error) {
-	outputFormat := OutputFormatStreamJSON
-	if options.OutputFormat != nil {
-		outputFormat = *options.OutputFormat
+// readOutput reads the messages in the output format of options, passing each to emit
+func readOutput(ctx context.Context, stdout io.ReadCloser, options *Options, stderr *stderrTail, log *queryLogger, emit func(Message) error) ([]Message, error) {
+	var messages []Message
+	var err error
+	switch options.outputFormat() {
+	case OutputFormatText:
+		messages, err = readTextOutput(stdout)
+	case OutputFormatJSON:
+		messages, err = readJSONOutput(stdout)
+	default:
+		return readMessages(ctx, stdout, options, stderr, log, emit)
 	}
+	if err != nil {
+		return nil, err
+	}
+	for i, message := range messages {
+		if result, ok := message.(*ResultMessage); ok {
+			log.tagSession(result.SessionID)
+		}
+		if err := emit(message); err != nil {
+			return messages[:i+1], err
+		}
+	}
+	return messages, nil
+}
 
-	if outputFormat == OutputFormatText {
-		return readTextOutput(stdout)
+// outputFormat returns the output format the CLI is asked for
+func (o *Options) outputFormat() OutputFormat {
+	if o.OutputFormat != nil {
+		return *o.OutputFormat
 	}
-	return readMessages(stdout)
+	return OutputFormatStreamJSON
 }
 
-func handleReadError(_ error, stderr io.ReadCloser) error {
-	stderrBytes, readErr := io.ReadAll(stderr)
-	if readErr != nil {
-		stderrBytes = []byte("failed to read stderr")
+func handleReadError(err error, cmd *exec.Cmd, stderr *stderrTail, log *queryLogger) error {
+	terminateCommand(cmd, stderr, log)
+	var stallErr *StallError
+	if errors.As(err, &stallErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
 	}
+	log.Warn("failed to read CLI output", "error", err, "stderr", log.redact(strings.TrimSpace(stderr.String())))
 	return &ProcessError{
 		ExitCode: -1,
-		Stderr:   string(stderrBytes),
+		Stderr:   stderr.String(),
 		Stdout:   "",
 	}
 }
 
-func waitForCommand(cmd *exec.Cmd, stderr io.ReadCloser) error {
+// terminateCommand kills the CLI process and reaps it. Stderr is drained
+// before waiting because Wait closes the pipe and would discard unread output.
+func terminateCommand(cmd *exec.Cmd, stderr *stderrTail, log *queryLogger) {
+	if cmd.Process != nil {
+		log.Debug("terminating CLI")
+		_ = cmd.Process.Kill()
+	}
+	stderr.drain(stderrDrainTimeout)
+	_ = cmd.Wait()
+}
+
+func waitForCommand(cmd *exec.Cmd, stderr *stderrTail, log *queryLogger) error {
+	stderr.drain(stderrDrainTimeout)
 	if err := cmd.Wait(); err != nil {
-		stderrBytes, readErr := io.ReadAll(stderr)
-		if readErr != nil {
-			stderrBytes = []byte("failed to read stderr")
-		}
 		if exitError, ok := err.(*exec.ExitError); ok {
+			log.exited(exitError.ExitCode(), stderr.String())
 			return &ProcessError{
 				ExitCode: exitError.ExitCode(),
-				Stderr:   string(stderrBytes),
+				Stderr:   stderr.String(),
 				Stdout:   "",
 			}
 		}
+		log.Warn("CLI process failed", "error", err)
 		return &CLIConnectionError{
 			Message: "CLI process failed",
 			Cause:   err,
 		}
 	}
+	log.exited(0, "")
 	return nil
 }
 
@@ -168,39 +256,70 @@ func QueryStream(ctx context.Context, prompt string, options *Options) (<-chan M
 		if options == nil {
 			options = &Options{}
 		}
-
-		os.Setenv("CLAUDE_CODE_ENTRYPOINT", "sdk-go")
-
-		streamOptions := prepareStreamOptions(options)
-		cmd, err := setupStreamCommand(ctx, &streamOptions)
+		handler := chainInterceptors(options.Interceptors, streamHandler)
+		err := handler(ctx, prompt, options, func(message Message) error {
+			select {
+			case messageChan <- message:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
 		if err != nil {
 			errorChan <- err
-			return
 		}
+	}()
 
-		stdin, stdout, stderr, err := createStreamPipes(cmd, errorChan)
-		if err != nil {
-			return
-		}
+	return messageChan, errorChan
+}
 
-		if err := cmd.Start(); err != nil {
-			errorChan <- &CLIConnectionError{
-				Message: "failed to start Claude CLI",
-				Cause:   err,
-			}
-			return
-		}
+// runQueryStream runs the CLI once with validated options, passing each message to emit
+func runQueryStream(ctx context.Context, prompt string, options *Options, emit func(Message) error) error {
+	options, stopApprover, err := prepareApprover(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer stopApprover()
+	options, removeMCPConfig, err := prepareMCPServers(options)
+	if err != nil {
+		return err
+	}
+	defer removeMCPConfig()
 
-		go sendPrompt(stdin, prompt)
+	os.Setenv("CLAUDE_CODE_ENTRYPOINT", "sdk-go")
+
+	streamOptions := prepareStreamOptions(options)
+	cmd, err := setupStreamCommand(ctx, &streamOptions)
+	if err != nil {
+		return err
+	}
+
+	stdin, stdout, stderr, err := createPipes(cmd)
+	if err != nil {
+		return err
+	}
 
-		if !streamMessages(ctx, stdout, messageChan, errorChan) {
-			return
+	log := newQueryLogger(options)
+	log.launching(cmd)
+	notifyProcessStart(ctx)
+	if err := cmd.Start(); err != nil {
+		log.Warn("failed to start CLI", "error", err)
+		return &CLIConnectionError{
+			Message: "failed to start Claude CLI",
+			Cause:   err,
 		}
+	}
+	log = log.startedProcess(cmd)
 
-		waitForStreamCommand(cmd, stderr, errorChan)
-	}()
+	stderrTail := captureStderr(stderr)
+	go sendPrompt(stdin, prompt)
 
-	return messageChan, errorChan
+	if err := scanMessages(ctx, stdout, &streamOptions, stderrTail, log, emit); err != nil {
+		terminateCommand(cmd, stderrTail, log)
+		return err
+	}
+
+	return waitForCommand(cmd, stderrTail, log)
 }
 
 func prepareStreamOptions(options *Options) Options {
@@ -226,105 +345,11 @@ func setupStreamCommand(ctx context.Context, options *Options) (*exec.Cmd, error
 	return cmd, nil
 }
 
-func createStreamPipes(cmd *exec.Cmd, errorChan chan<- error) (io.WriteCloser, io.ReadCloser, io.ReadCloser, error) {
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		errorChan <- &CLIConnectionError{
-			Message: "failed to create stdin pipe",
-			Cause:   err,
-		}
-		return nil, nil, nil, err
-	}
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		errorChan <- &CLIConnectionError{
-			Message: "failed to create stdout pipe",
-			Cause:   err,
-		}
-		return nil, nil, nil, err
-	}
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		errorChan <- &CLIConnectionError{
-			Message: "failed to create stderr pipe",
-			Cause:   err,
-		}
-		return nil, nil, nil, err
-	}
-
-	return stdin, stdout, stderr, nil
-}
-
 func sendPrompt(stdin io.WriteCloser, prompt string) {
 	defer stdin.Close()
 	_, _ = stdin.Write([]byte(prompt))
 }
 
-func streamMessages(ctx context.Context, stdout io.ReadCloser, messageChan chan<- Message, errorChan chan<- error) bool {
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
-		}
-
-		var rawMessage map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &rawMessage); err != nil {
-			errorChan <- &CLIJSONDecodeError{
-				Data:  line,
-				Cause: err,
-			}
-			return false
-		}
-
-		message, err := parseMessage(rawMessage)
-		if err != nil {
-			errorChan <- err
-			return false
-		}
-
-		select {
-		case messageChan <- message:
-		case <-ctx.Done():
-			errorChan <- ctx.Err()
-			return false
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		errorChan <- &CLIConnectionError{
-			Message: "error reading CLI output",
-			Cause:   err,
-		}
-		return false
-	}
-
-	return true
-}
-
-func waitForStreamCommand(cmd *exec.Cmd, stderr io.ReadCloser, errorChan chan<- error) {
-	if err := cmd.Wait(); err != nil {
-		stderrBytes, readErr := io.ReadAll(stderr)
-		if readErr != nil {
-			stderrBytes = []byte("failed to read stderr")
-		}
-		if exitError, ok := err.(*exec.ExitError); ok {
-			errorChan <- &ProcessError{
-				ExitCode: exitError.ExitCode(),
-				Stderr:   string(stderrBytes),
-				Stdout:   "",
-			}
-		} else {
-			errorChan <- &CLIConnectionError{
-				Message: "CLI process failed",
-				Cause:   err,
-			}
-		}
-	}
-}
-
 // findCLIExecutable finds the Claude Code CLI executable
 func findCLIExecutable(customPath *string) (string, error) {
 	if customPath != nil && *customPath != "" {
@@ -410,6 +435,9 @@ func addSessionArgs(args []string, options *Options) []string {
 	if options.Continue != nil && *options.Continue {
 		args = append(args, "--continue")
 	}
+	if options.ForkSession != nil && *options.ForkSession {
+		args = append(args, "--fork-session")
+	}
 	return args
 }
 
@@ -425,6 +453,10 @@ func addOutputArgs(args []string, options *Options) []string {
 	} else if outputFormat == OutputFormatStreamJSON {
 		args = append(args, "--verbose")
 	}
+
+	if options.IncludePartialMessages != nil && *options.IncludePartialMessages {
+		args = append(args, "--include-partial-messages")
+	}
 	return args
 }
 
@@ -432,21 +464,49 @@ func addMCPArgs(args []string, options *Options) []string {
 	if options.MCPConfig != nil && *options.MCPConfig != "" {
 		args = append(args, "--mcp-config", *options.MCPConfig)
 	}
-	if len(options.MCPServers) > 0 {
-		mcpConfig := map[string]interface{}{
-			"mcpServers": options.MCPServers,
-		}
-		configJSON, err := json.Marshal(mcpConfig)
-		if err == nil {
-			args = append(args, "--mcp-config", string(configJSON))
-		}
+	if options.mcpServersFile != "" {
+		args = append(args, "--mcp-config", options.mcpServersFile)
 	}
 	return args
 }
 
+// prepareMCPServers writes Options.MCPServers to a temporary config file that
+// only the current user can read, keeping their env values and headers, such
+// as expanded ${VAR} secrets, out of the command line, which other local users
+// can see. It returns the options to launch with and a func removing the file.
+func prepareMCPServers(options *Options) (*Options, func(), error) {
+	if len(options.MCPServers) == 0 {
+		return options, func() {}, nil
+	}
+	configJSON, err := json.Marshal(map[string]interface{}{"mcpServers": options.MCPServers})
+	if err != nil {
+		return nil, nil, &ClaudeSDKError{Message: "failed to encode MCP servers", Cause: err}
+	}
+
+	// CreateTemp creates the file with mode 0600
+	file, err := os.CreateTemp("", "claude-mcp-*.json")
+	if err != nil {
+		return nil, nil, &ClaudeSDKError{Message: "failed to create MCP config file", Cause: err}
+	}
+	remove := func() { os.Remove(file.Name()) }
+	_, err = file.Write(configJSON)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		remove()
+		return nil, nil, &ClaudeSDKError{Message: "failed to write MCP config file", Cause: err}
+	}
+
+	prepared := *options
+	prepared.MCPServers = nil
+	prepared.mcpServersFile = file.Name()
+	return &prepared, remove, nil
+}
+
 func addPermissionArgs(args []string, options *Options) []string {
 	if options.PermissionMode != nil && *options.PermissionMode != "" {
-		args = append(args, "--permission-mode", *options.PermissionMode)
+		args = append(args, "--permission-mode", string(*options.PermissionMode))
 	}
 	if options.PermissionPromptTool != nil && *options.PermissionPromptTool != "" {
 		args = append(args, "--permission-prompt-tool", *options.PermissionPromptTool)
@@ -462,7 +522,7 @@ func addMiscArgs(args []string, options *Options) []string {
 		args = append(args, "--debug")
 	}
 	if options.InputFormat != nil && *options.InputFormat != "" {
-		args = append(args, "--input-format", *options.InputFormat)
+		args = append(args, "--input-format", string(*options.InputFormat))
 	}
 	if len(options.AddDir) > 0 {
 		for _, dir := range options.AddDir {
@@ -494,44 +554,67 @@ func readTextOutput(reader io.Reader) ([]Message, error) {
 	return []Message{message}, nil
 }
 
-// readMessages reads and parses messages from the CLI output
-func readMessages(reader io.Reader) ([]Message, error) {
-	var messages []Message
-	scanner := bufio.NewScanner(reader)
+// readJSONOutput reads the single JSON document printed in json output mode.
+// The document is either one result object or, with --verbose, an array of messages.
+func readJSONOutput(reader io.Reader) ([]Message, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, &CLIConnectionError{
+			Message: "error reading JSON output",
+			Cause:   err,
+		}
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
+	var document interface{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, &CLIJSONDecodeError{
+			Data:  string(content),
+			Cause: err,
 		}
+	}
 
-		// Parse JSON message
-		var rawMessage map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &rawMessage); err != nil {
+	var rawMessages []interface{}
+	switch doc := document.(type) {
+	case []interface{}:
+		rawMessages = doc
+	case map[string]interface{}:
+		rawMessages = []interface{}{doc}
+	default:
+		return nil, &CLIJSONDecodeError{
+			Data:  string(content),
+			Cause: fmt.Errorf("expected JSON object or array"),
+		}
+	}
+
+	messages := make([]Message, 0, len(rawMessages))
+	for _, rawMessage := range rawMessages {
+		messageMap, ok := rawMessage.(map[string]interface{})
+		if !ok {
 			return nil, &CLIJSONDecodeError{
-				Data:  line,
-				Cause: err,
+				Data:  fmt.Sprintf("%v", rawMessage),
+				Cause: fmt.Errorf("invalid message format"),
 			}
 		}
-
-		message, err := parseMessage(rawMessage)
+		message, err := parseMessage(messageMap)
 		if err != nil {
 			return nil, err
 		}
-
 		messages = append(messages, message)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, &CLIConnectionError{
-			Message: "error reading CLI output",
-			Cause:   err,
-		}
-	}
-
 	return messages, nil
 }
 
+// readMessages reads and parses messages from the CLI output
+func readMessages(ctx context.Context, reader io.Reader, options *Options, stderr *stderrTail, log *queryLogger, emit func(Message) error) ([]Message, error) {
+	var messages []Message
+	err := scanMessages(ctx, reader, options, stderr, log, func(message Message) error {
+		messages = append(messages, message)
+		return emit(message)
+	})
+	return messages, err
+}
+
 // parseMessage parses a raw message map into a Message interface
 func parseMessage(rawMessage map[string]interface{}) (Message, error) {
 	messageType, ok := rawMessage["type"].(string)
@@ -554,6 +637,8 @@ func parseMessage(rawMessage map[string]interface{}) (Message, error) {
 		return parseUserMessage(rawMessage, sessionID, parentToolUseIDPtr, timestamp)
 	case "result":
 		return parseResultMessage(rawMessage, sessionID, timestamp)
+	case MessageTypeStreamEvent:
+		return parseStreamEvent(rawMessage, sessionID, parentToolUseIDPtr, timestamp)
 	default:
 		return &SystemMessage{
 			Subtype:   messageType,
@@ -621,6 +706,7 @@ func parseUserMessage(rawMessage map[string]interface{}, sessionID string, paren
 		ContentBlocks:   contentBlocks,
 		ParentToolUseID: parentToolUseIDPtr,
 		SessionID:       sessionID,
+		ToolUseResult:   rawMessage["tool_use_result"],
 		CreatedAt:       timestamp,
 	}, nil
 }
@@ -812,10 +898,18 @@ func parseContentBlock(rawBlock interface{}) (ContentBlock, error) {
 			IsError:   isError,
 		}, nil
 
+	case ContentBlockTypeThinking:
+		thinking, _ := blockMap["thinking"].(string)
+		signature, _ := blockMap["signature"].(string)
+		return &ThinkingBlock{
+			Thinking:  thinking,
+			Signature: signature,
+		}, nil
+
 	default:
-		return nil, &CLIJSONDecodeError{
-			Data:  fmt.Sprintf("%v", rawBlock),
-			Cause: fmt.Errorf("unknown content block type: %s", blockType),
-		}
+		return &RawBlock{
+			BlockType: ContentBlockType(blockType),
+			Data:      blockMap,
+		}, nil
 	}
 }
@@ -2,6 +2,10 @@ package claudecode
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 )
@@ -181,7 +185,48 @@ func TestQuery(t *testing.T) {
 	}
 }
 
+func TestQueryCLIExitsBeforeReadingPrompt(t *testing.T) {
+	// The prompt is larger than a pipe buffer, so writing it fails once the CLI has exited
+	cli := writeFakeCLI(t, `echo "Invalid API key" >&2; exit 1`)
+	prompt := string(make([]byte, 1<<20))
+	for i := 0; i < 5; i++ {
+		_, err := Query(context.Background(), prompt, &Options{Executable: &cli})
+		var processErr *ProcessError
+		if !errors.As(err, &processErr) || processErr.ExitCode != 1 || !contains(processErr.Stderr, "Invalid API key") {
+			t.Fatalf("Expected the CLI's process error, got %T: %v", err, err)
+		}
+	}
+}
+
+func TestQueryReturnsPartialMessages(t *testing.T) {
+	cli := writeFakeCLI(t, `
+cat >/dev/null
+echo '{"type":"system","subtype":"init","session_id":"s1"}'
+echo "Invalid API key" >&2
+exit 1
+`)
+	messages, err := Query(context.Background(), "hi", &Options{Executable: &cli})
+	var processErr *ProcessError
+	if !errors.As(err, &processErr) || len(messages) != 1 || messages[0].Type() != MessageTypeSystem {
+		t.Errorf("Expected the messages read before the failure with the error, got %v %v", messages, err)
+	}
+}
+
 // Helper functions
+
+// writeFakeCLI writes an executable shell script standing in for the Claude CLI
+func writeFakeCLI(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake CLI scripts require a POSIX shell")
+	}
+	path := filepath.Join(t.TempDir(), "claude")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatalf("Failed to write fake CLI: %v", err)
+	}
+	return path
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr ||
 		(len(s) > len(substr) && (s[:len(substr)] == substr ||
@@ -205,3 +250,7 @@ func stringPtr(s string) *string {
 func intPtr(i int) *int {
 	return &i
 }
+
+func boolPtr(b bool) *bool {
+	return &b
+}
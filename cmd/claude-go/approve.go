@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	claudecode "github.com/kannae97/claude-code-sdk-go"
+)
+
+// policyApprover decides tool uses with the allowed and disallowed rules. Uses
+// the rules neither allow nor deny are denied, or asked about when interactive.
+type policyApprover struct {
+	policy *claudecode.PermissionPolicy
+
+	// prompt and answers are set in interactive mode
+	prompt  io.Writer
+	answers *bufio.Reader
+	mu      sync.Mutex
+}
+
+func newApprover(options *claudecode.Options, interactive bool) (*policyApprover, error) {
+	policy, err := claudecode.NewPermissionPolicy(options)
+	if err != nil {
+		return nil, err
+	}
+	approver := &policyApprover{policy: policy}
+	if interactive {
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			return nil, fmt.Errorf("-approve=prompt needs a terminal: %w", err)
+		}
+		approver.prompt = tty
+		approver.answers = bufio.NewReader(tty)
+	}
+	return approver, nil
+}
+
+func (a *policyApprover) Approve(ctx context.Context, request claudecode.ApprovalRequest) (claudecode.ApprovalDecision, error) {
+	block := &claudecode.ToolUseBlock{ID: request.ToolUseID, Name: request.ToolName, Input: request.Input}
+	decision, rule := a.policy.Decide(block)
+	switch decision {
+	case claudecode.PermissionAllow:
+		return claudecode.ApprovalDecision{Allow: true}, nil
+	case claudecode.PermissionDeny:
+		return claudecode.ApprovalDecision{Message: fmt.Sprintf("denied by rule %s", rule)}, nil
+	}
+
+	if a.answers == nil {
+		return claudecode.ApprovalDecision{Message: fmt.Sprintf("%s is not allowed by the configured rules", request.ToolName)}, nil
+	}
+
+	// Prompts are serialized so concurrent requests do not interleave
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fmt.Fprintf(a.prompt, "\nAllow %s %s? [y/N] ", request.ToolName, block.Summary())
+	answer, err := a.answers.ReadString('\n')
+	if err != nil && answer == "" {
+		return claudecode.ApprovalDecision{}, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return claudecode.ApprovalDecision{Allow: true}, nil
+	}
+	return claudecode.ApprovalDecision{Message: "denied by the user"}, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	claudecode "github.com/kannae97/claude-code-sdk-go"
+)
+
+// runBatch runs the BatchRequests of a JSONL file. Requests without options use
+// the options given by flags and profile. With -o, results are appended to the
+// file and requests it already holds successful results for are skipped, so an
+// interrupted batch can be resumed by running the same command again.
+func runBatch(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("batch", "claude-go batch [flags] <requests.jsonl>", stderr)
+	optionFlags := newOptionFlags(fs)
+	output := fs.String("o", "", "append results to this file, skipping requests it already completed")
+	concurrency := fs.Int("concurrency", 4, "number of queries to run at once")
+	ordered := fs.Bool("ordered", true, "write results in input order rather than completion order")
+	omitMessages := fs.Bool("omit-messages", false, "leave the messages out of the results")
+	options, err := parseOptions(fs, optionFlags, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	input, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	batchOptions := claudecode.BatchOptions{
+		Concurrency:  *concurrency,
+		Ordered:      *ordered,
+		Options:      options,
+		OmitMessages: *omitMessages,
+	}
+
+	writer := stdout
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if batchOptions.Skip, err = claudecode.ReadBatchResultIDs(file); err != nil {
+			return err
+		}
+		writer = file
+	}
+
+	stats, err := claudecode.RunBatch(ctx, input, writer, batchOptions)
+	if stats != nil && *output != "" {
+		fmt.Fprintf(stderr, "%d requests: %d succeeded, %d failed, %d skipped\n",
+			stats.Total, stats.Succeeded, stats.Failed, stats.Skipped)
+	}
+	if err != nil {
+		return err
+	}
+	if stats.Failed > 0 {
+		return fmt.Errorf("%d batch requests failed", stats.Failed)
+	}
+	return nil
+}
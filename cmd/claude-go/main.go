@@ -0,0 +1,258 @@
+// Command claude-go runs Claude Code through the Go SDK.
+//
+// Usage:
+//
+//	claude-go [flags] [prompt]           run a query (the prompt is read from stdin when omitted or "-")
+//	claude-go sessions [-project dir]    list the stored sessions of a project
+//	claude-go show [-format f] <id>      render a stored session as markdown, html or jsonl
+//	claude-go batch [flags] <file>       run the JSONL QueryRequests in file, writing JSONL results
+//
+// Query flags can be collected into named profiles in a JSON config file:
+//
+//	{
+//	  "default_profile": "review",
+//	  "profiles": {
+//	    "review": {"model": "sonnet", "permission-mode": "plan", "allowed-tools": ["Read", "Grep"]}
+//	  }
+//	}
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	claudecode "github.com/kannae97/claude-code-sdk-go"
+	"github.com/kannae97/claude-code-sdk-go/render"
+)
+
+// Output formats of a query
+const (
+	outputPretty   = "pretty"
+	outputText     = "text"
+	outputMarkdown = "markdown"
+	outputJSONL    = "jsonl"
+)
+
+// errUsage reports a command-line mistake, already explained on stderr
+var errUsage = errors.New("usage error")
+
+func main() {
+	// -approve leaves ApproverExecutable unset, so the CLI runs this binary again
+	// as the permission prompt MCP server; it must serve before doing anything else
+	if claudecode.ServeApprover() {
+		return
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run executes the command and returns the process exit code
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var err error
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "sessions":
+		err = runSessions(args[1:], stdout, stderr)
+	case "show":
+		err = runShow(args[1:], stdout, stderr)
+	case "batch":
+		err = runBatch(ctx, args[1:], stdout, stderr)
+	default:
+		err = runQuery(ctx, args, stdin, stdout, stderr)
+	}
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		return 2
+	}
+	fmt.Fprintf(stderr, "claude-go: %v\n", err)
+	return 1
+}
+
+func newFlagSet(name, usage string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s\n\nFlags:\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseOptions parses the query flags, applies the selected profile and builds Options
+func parseOptions(fs *flag.FlagSet, optionFlags *optionFlags, args []string) (*claudecode.Options, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := optionFlags.applyProfile(); err != nil {
+		return nil, err
+	}
+	return optionFlags.options()
+}
+
+func runQuery(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("claude-go", "claude-go [flags] [prompt]", stderr)
+	optionFlags := newOptionFlags(fs)
+	output := fs.String("output", outputPretty, "output format: pretty, text, markdown or jsonl")
+	showThinking := fs.Bool("show-thinking", false, "print thinking blocks in pretty output")
+	options, err := parseOptions(fs, optionFlags, args)
+	if err != nil {
+		return err
+	}
+
+	prompt := strings.Join(fs.Args(), " ")
+	if prompt == "" || prompt == "-" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt: %w", err)
+		}
+		prompt = strings.TrimSpace(string(data))
+	}
+	if prompt == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	switch *output {
+	case outputPretty:
+		messages, errs := claudecode.QueryStream(ctx, prompt, options)
+		printer := render.NewStreamPrinter(stdout, &render.PrinterOptions{ShowThinking: *showThinking})
+		return printer.Run(ctx, messages, errs)
+
+	case outputJSONL:
+		messages, errs := claudecode.QueryStream(ctx, prompt, options)
+		return writeJSONL(stdout, messages, errs)
+
+	case outputText, outputMarkdown:
+		messages, err := claudecode.Query(ctx, prompt, options)
+		if err != nil {
+			return err
+		}
+		if *output == outputMarkdown {
+			return render.Markdown(stdout, messages, nil)
+		}
+		return writeResultText(stdout, messages)
+	}
+
+	fmt.Fprintf(stderr, "unknown -output %q\n", *output)
+	return errUsage
+}
+
+func writeJSONL(w io.Writer, messages <-chan claudecode.Message, errs <-chan error) error {
+	var firstErr error
+	for message := range messages {
+		data, err := claudecode.MarshalMessage(message)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", data); err != nil {
+			return err
+		}
+	}
+	for err := range errs {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func writeResultText(w io.Writer, messages []claudecode.Message) error {
+	for i := len(messages) - 1; i >= 0; i-- {
+		result, ok := messages[i].(*claudecode.ResultMessage)
+		if !ok {
+			continue
+		}
+		if result.Result != nil {
+			fmt.Fprintln(w, *result.Result)
+		}
+		if result.IsError {
+			return fmt.Errorf("query failed: %s", result.Subtype)
+		}
+		return nil
+	}
+	return errors.New("no result message received")
+}
+
+func runSessions(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("sessions", "claude-go sessions [-project dir] [-limit n]", stderr)
+	project := fs.String("project", "", "project directory (default: the current directory)")
+	limit := fs.Int("limit", 20, "maximum number of sessions to list; 0 lists all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	sessions, err := claudecode.ListSessions(*project)
+	if err != nil {
+		return err
+	}
+	if *limit > 0 && len(sessions) > *limit {
+		sessions = sessions[:*limit]
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUPDATED\tMESSAGES\tCOST\tFIRST PROMPT")
+	for _, session := range sessions {
+		prompt := strings.Join(strings.Fields(session.FirstPrompt), " ")
+		if runes := []rune(prompt); len(runes) > 60 {
+			prompt = string(runes[:59]) + "…"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t$%.4f\t%s\n", session.ID,
+			session.UpdatedAt.Local().Format(time.DateTime), session.MessageCount, session.CostUSD, prompt)
+	}
+	return tw.Flush()
+}
+
+func runShow(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("show", "claude-go show [-format markdown|html|jsonl] <session-id>", stderr)
+	format := fs.String("format", outputMarkdown, "output format: markdown, html or jsonl")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	messages, err := claudecode.LoadSession(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	options := &render.Options{Title: fmt.Sprintf("Session %s", fs.Arg(0))}
+
+	switch *format {
+	case outputMarkdown:
+		return render.Markdown(stdout, messages, options)
+	case "html":
+		return render.HTML(stdout, messages, options)
+	case outputJSONL:
+		messageChan := make(chan claudecode.Message, len(messages))
+		for _, message := range messages {
+			messageChan <- message
+		}
+		close(messageChan)
+		errs := make(chan error)
+		close(errs)
+		return writeJSONL(stdout, messageChan, errs)
+	}
+	fmt.Fprintf(stderr, "unknown -format %q\n", *format)
+	return errUsage
+}
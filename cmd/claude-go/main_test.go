@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	claudecode "github.com/kannae97/claude-code-sdk-go"
+)
+
+// fakeCLI answers every prompt with a result echoing it
+const fakeCLI = `#!/bin/sh
+prompt=$(cat)
+echo '{"type":"system","subtype":"init","session_id":"s1","model":"sonnet"}'
+echo '{"type":"assistant","session_id":"s1","message":{"role":"assistant","content":[{"type":"text","text":"thinking about '"$prompt"'"}]}}'
+echo '{"type":"result","subtype":"success","session_id":"s1","num_turns":1,"total_cost_usd":0.01,"result":"answer to '"$prompt"'"}'
+`
+
+func writeFakeCLI(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "claude")
+	if err := os.WriteFile(path, []byte(fakeCLI), 0o755); err != nil {
+		t.Fatalf("Failed to write fake CLI: %v", err)
+	}
+	return path
+}
+
+func runCommand(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestProfiles(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(configFile, []byte(`{
+		"default_profile": "review",
+		"profiles": {
+			"review": {"model": "sonnet", "allowed-tools": ["Read", "Bash(git:*)"], "max-turns": 3, "fork": true, "continue": true},
+			"broken": {"modle": "opus"}
+		}
+	}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := newFlagSet("test", "test", &bytes.Buffer{})
+	options, err := parseOptions(fs, newOptionFlags(fs), []string{"-config", configFile, "-model", "opus", "prompt"})
+	if err != nil {
+		t.Fatalf("parseOptions failed: %v", err)
+	}
+	if *options.Model != "opus" {
+		t.Errorf("Expected command-line model to override the profile, got %s", *options.Model)
+	}
+	if *options.MaxTurns != 3 || !*options.ForkSession || !*options.Continue {
+		t.Errorf("Expected profile values to apply, got %+v", options)
+	}
+	if strings.Join(options.AllowedTools, " ") != "Read Bash(git:*)" {
+		t.Errorf("Unexpected allowed tools: %v", options.AllowedTools)
+	}
+	if options.Resume != nil || options.Cwd != nil {
+		t.Error("Expected unset flags to leave options nil")
+	}
+
+	fs = newFlagSet("test", "test", &bytes.Buffer{})
+	if _, err := parseOptions(fs, newOptionFlags(fs), []string{"-config", configFile, "-profile", "broken"}); err == nil || !strings.Contains(err.Error(), `unknown option "modle"`) {
+		t.Errorf("Expected unknown profile option error, got %v", err)
+	}
+	code, _, stderr := runCommand(t, "", "-config", configFile, "-profile", "missing", "hi")
+	if code != 1 || !strings.Contains(stderr, `profile "missing" not found`) {
+		t.Errorf("Expected missing profile error, got %d %q", code, stderr)
+	}
+}
+
+func TestStringListSet(t *testing.T) {
+	var list stringList
+	for _, value := range []string{"Read, Edit(src/**)", "Bash(git diff:*, git log:*)", ""} {
+		if err := list.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{"Read", "Edit(src/**)", "Bash(git diff:*, git log:*)"}
+	if strings.Join(list, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q, got %q", expected, list)
+	}
+}
+
+func TestQueryOutputs(t *testing.T) {
+	t.Setenv("CLAUDE_GO_CONFIG", filepath.Join(t.TempDir(), "none.json"))
+	cli := writeFakeCLI(t)
+
+	code, stdout, stderr := runCommand(t, "", "-executable", cli, "-output", "text", "hello")
+	if code != 0 || stdout != "answer to hello\n" {
+		t.Errorf("Unexpected text output: %d %q %q", code, stdout, stderr)
+	}
+
+	code, stdout, _ = runCommand(t, "from stdin\n", "-executable", cli, "-output", "markdown")
+	if code != 0 || !strings.Contains(stdout, "thinking about from stdin") || !strings.Contains(stdout, "| s1 | sonnet |") {
+		t.Errorf("Unexpected markdown output: %d %q", code, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, "", "-executable", cli, "-output", "jsonl", "hi")
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if code != 0 || len(lines) != 3 {
+		t.Fatalf("Expected 3 JSONL messages, got %d %q", code, stdout)
+	}
+	message, err := claudecode.UnmarshalMessage([]byte(lines[2]))
+	if err != nil || message.Type() != claudecode.MessageTypeResult {
+		t.Errorf("Expected result message on the last line, got %v %v", message, err)
+	}
+
+	code, stdout, _ = runCommand(t, "", "-executable", cli, "hi")
+	if code != 0 || !strings.Contains(stdout, "thinking about hi") || !strings.Contains(stdout, "── success") {
+		t.Errorf("Unexpected pretty output: %d %q", code, stdout)
+	}
+
+	if code, _, _ := runCommand(t, "", "-executable", cli); code != 2 {
+		t.Errorf("Expected usage error without a prompt, got %d", code)
+	}
+	if code, _, _ := runCommand(t, "", "-executable", cli, "-approve", "maybe", "hi"); code != 1 {
+		t.Errorf("Expected error for unknown approve mode, got %d", code)
+	}
+}
+
+func TestSessionsAndShow(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("CLAUDE_CONFIG_DIR", configDir)
+	projectDir := filepath.Join(configDir, "projects", "-work-project")
+	if err := os.MkdirAll(projectDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	transcript := `{"type":"user","message":{"role":"user","content":"Explain the build"},"timestamp":"2025-01-02T10:00:00Z","sessionId":"abc"}
+{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"It uses make."}]},"timestamp":"2025-01-02T10:00:05Z","sessionId":"abc"}
+`
+	if err := os.WriteFile(filepath.Join(projectDir, "abc.jsonl"), []byte(transcript), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCommand(t, "", "sessions", "-project", "/work/project")
+	if code != 0 || !strings.Contains(stdout, "abc") || !strings.Contains(stdout, "Explain the build") {
+		t.Errorf("Unexpected sessions output: %d %q %q", code, stdout, stderr)
+	}
+
+	code, stdout, _ = runCommand(t, "", "show", "abc")
+	if code != 0 || !strings.Contains(stdout, "# Session abc") || !strings.Contains(stdout, "It uses make.") {
+		t.Errorf("Unexpected show output: %d %q", code, stdout)
+	}
+	if code, _, _ := runCommand(t, "", "show", "missing"); code != 1 {
+		t.Errorf("Expected error for missing session, got %d", code)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	t.Setenv("CLAUDE_GO_CONFIG", filepath.Join(t.TempDir(), "none.json"))
+	cli := writeFakeCLI(t)
+	requests := filepath.Join(t.TempDir(), "requests.jsonl")
+	err := os.WriteFile(requests, []byte(`{"prompt":"one"}
+
+{"prompt":"two"}
+not json
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCommand(t, "", "batch", "-executable", cli, requests)
+	if code != 1 || !strings.Contains(stderr, "1 batch requests failed") {
+		t.Errorf("Expected the invalid line to fail the batch, got %d %q", code, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 results, got %q", stdout)
+	}
+	if !strings.Contains(lines[0], `"index":0`) || !strings.Contains(lines[0], `"result":"answer to one"`) {
+		t.Errorf("Unexpected first result: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"result":"answer to two"`) || !strings.Contains(lines[2], `"error":"invalid request`) {
+		t.Errorf("Unexpected results: %s", stdout)
+	}
+
+	output := filepath.Join(t.TempDir(), "results.jsonl")
+	runCommand(t, "", "batch", "-executable", cli, "-o", output, requests)
+	_, _, stderr = runCommand(t, "", "batch", "-executable", cli, "-o", output, requests)
+	if !strings.Contains(stderr, "3 requests: 0 succeeded, 1 failed, 2 skipped") {
+		t.Errorf("Expected the resumed batch to skip completed requests, got %q", stderr)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 4 {
+		t.Errorf("Expected results to be appended, got %d lines", len(lines))
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	claudecode "github.com/kannae97/claude-code-sdk-go"
+)
+
+// stringList is a repeatable flag that also accepts comma-separated values.
+// Commas inside parentheses, as in "Bash(git diff:*, git log:*)", do not separate values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	depth, start := 0, 0
+	add := func(item string) {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	for i, r := range value {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case r == ',' && depth == 0:
+			add(value[start:i])
+			start = i + 1
+		}
+	}
+	add(value[start:])
+	return nil
+}
+
+// optionFlags maps command-line flags and profile keys onto claudecode.Options
+type optionFlags struct {
+	fs *flag.FlagSet
+
+	profile string
+	config  string
+
+	model              string
+	systemPrompt       string
+	appendSystemPrompt string
+	maxTurns           int
+	permissionMode     string
+	allowedTools       stringList
+	disallowedTools    stringList
+	cwd                string
+	addDirs            stringList
+	mcpConfig          string
+	resume             string
+	continueSession    bool
+	fork               bool
+	executable         string
+	verbose            bool
+	idleTimeout        time.Duration
+	turnTimeout        time.Duration
+	approve            string
+}
+
+func newOptionFlags(fs *flag.FlagSet) *optionFlags {
+	f := &optionFlags{fs: fs}
+	fs.StringVar(&f.profile, "profile", "", "config profile to apply (default: the config's default_profile)")
+	fs.StringVar(&f.config, "config", "", "config file (default: $CLAUDE_GO_CONFIG or <user config dir>/claude-go/config.json)")
+	fs.StringVar(&f.model, "model", "", "model alias or name")
+	fs.StringVar(&f.systemPrompt, "system-prompt", "", "replace the system prompt")
+	fs.StringVar(&f.appendSystemPrompt, "append-system-prompt", "", "append to the system prompt")
+	fs.IntVar(&f.maxTurns, "max-turns", 0, "limit the number of turns")
+	fs.StringVar(&f.permissionMode, "permission-mode", "", "default, acceptEdits, bypassPermissions or plan")
+	fs.Var(&f.allowedTools, "allowed-tools", "permission rules to allow, e.g. Read,Bash(git:*) (repeatable)")
+	fs.Var(&f.disallowedTools, "disallowed-tools", "permission rules to deny (repeatable)")
+	fs.StringVar(&f.cwd, "cwd", "", "working directory")
+	fs.Var(&f.addDirs, "add-dir", "additional directory Claude may access (repeatable)")
+	fs.StringVar(&f.mcpConfig, "mcp-config", "", "MCP config file or JSON string")
+	fs.StringVar(&f.resume, "resume", "", "resume a session by ID")
+	fs.BoolVar(&f.continueSession, "continue", false, "continue the latest session")
+	fs.BoolVar(&f.fork, "fork", false, "fork the resumed or continued session into a new one")
+	fs.StringVar(&f.executable, "executable", "", "path to the claude CLI")
+	fs.BoolVar(&f.verbose, "verbose", false, "enable verbose CLI logging")
+	fs.DurationVar(&f.idleTimeout, "idle-timeout", 0, "abort when the CLI prints nothing for this long")
+	fs.DurationVar(&f.turnTimeout, "turn-timeout", 0, "abort when a single turn takes this long")
+	fs.StringVar(&f.approve, "approve", "", "approve tool uses in Go: policy (deny what the rules do not allow) or prompt (ask on the terminal)")
+	return f
+}
+
+// config is the claude-go config file
+type config struct {
+	DefaultProfile string                            `json:"default_profile"`
+	Profiles       map[string]map[string]interface{} `json:"profiles"`
+}
+
+func configPath(explicit string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if path := os.Getenv("CLAUDE_GO_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "claude-go", "config.json")
+}
+
+func loadConfig(path string) (*config, error) {
+	cfg := &config{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// applyProfile sets the flags named by the selected profile, except those given
+// on the command line, which take precedence
+func (f *optionFlags) applyProfile() error {
+	path := configPath(f.config)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	name := f.profile
+	if name == "" {
+		name = cfg.DefaultProfile
+	}
+	if name == "" {
+		return nil
+	}
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %q not found in %s", name, path)
+	}
+
+	explicit := map[string]bool{}
+	f.fs.Visit(func(fl *flag.Flag) { explicit[fl.Name] = true })
+
+	keys := make([]string, 0, len(profile))
+	for key := range profile {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "profile" || key == "config" || f.fs.Lookup(key) == nil {
+			return fmt.Errorf("profile %q: unknown option %q", name, key)
+		}
+		if explicit[key] {
+			continue
+		}
+		values, ok := profile[key].([]interface{})
+		if !ok {
+			values = []interface{}{profile[key]}
+		}
+		for _, value := range values {
+			if err := f.fs.Set(key, fmt.Sprint(value)); err != nil {
+				return fmt.Errorf("profile %q: invalid %s: %w", name, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// options builds Options from the flags that were set
+func (f *optionFlags) options() (*claudecode.Options, error) {
+	options := &claudecode.Options{}
+	set := map[string]bool{}
+	f.fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+
+	if set["model"] {
+		options.Model = &f.model
+	}
+	if set["system-prompt"] {
+		options.SystemPrompt = &f.systemPrompt
+	}
+	if set["append-system-prompt"] {
+		options.AppendSystemPrompt = &f.appendSystemPrompt
+	}
+	if set["max-turns"] {
+		options.MaxTurns = &f.maxTurns
+	}
+	if set["permission-mode"] {
+		mode := claudecode.PermissionMode(f.permissionMode)
+		options.PermissionMode = &mode
+	}
+	options.AllowedTools = f.allowedTools
+	options.DisallowedTools = f.disallowedTools
+	if set["cwd"] {
+		options.Cwd = &f.cwd
+	}
+	options.AddDir = f.addDirs
+	if set["mcp-config"] {
+		options.MCPConfig = &f.mcpConfig
+	}
+	if set["resume"] {
+		options.Resume = &f.resume
+	}
+	if set["continue"] {
+		options.Continue = &f.continueSession
+	}
+	if set["fork"] {
+		options.ForkSession = &f.fork
+	}
+	if set["executable"] {
+		options.Executable = &f.executable
+	}
+	if set["verbose"] {
+		options.Verbose = &f.verbose
+	}
+	if set["idle-timeout"] {
+		options.IdleTimeout = &f.idleTimeout
+	}
+	if set["turn-timeout"] {
+		options.TurnTimeout = &f.turnTimeout
+	}
+
+	switch f.approve {
+	case "":
+	case "policy", "prompt":
+		approver, err := newApprover(options, f.approve == "prompt")
+		if err != nil {
+			return nil, err
+		}
+		options.Approver = approver
+	default:
+		return nil, fmt.Errorf("unknown -approve mode %q (want policy or prompt)", f.approve)
+	}
+	return options, nil
+}
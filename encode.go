@@ -0,0 +1,104 @@
+package claudecode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MarshalMessage encodes a message in the CLI's stream-json format, with typed
+// content blocks and an RFC 3339 timestamp, so that it can be written as JSONL
+// and parsed back into the same message
+func MarshalMessage(message Message) ([]byte, error) {
+	raw, err := messageToMap(message)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalMessage parses a message encoded by MarshalMessage or emitted by the CLI
+func UnmarshalMessage(data []byte) (Message, error) {
+	var rawMessage map[string]interface{}
+	if err := json.Unmarshal(data, &rawMessage); err != nil {
+		return nil, &CLIJSONDecodeError{Data: string(data), Cause: err}
+	}
+	return parseMessage(rawMessage)
+}
+
+func messageToMap(message Message) (map[string]interface{}, error) {
+	var raw map[string]interface{}
+	switch m := message.(type) {
+	case *AssistantMessage:
+		raw = conversationMessageMap("assistant", m.ContentBlocks, m.SessionID, m.ParentToolUseID)
+	case *UserMessage:
+		raw = conversationMessageMap("user", m.ContentBlocks, m.SessionID, m.ParentToolUseID)
+		if m.ToolUseResult != nil {
+			raw["tool_use_result"] = m.ToolUseResult
+		}
+	case *SystemMessage, *ResultMessage:
+		data, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		delete(raw, "created_at")
+	case *StreamEvent:
+		raw = map[string]interface{}{
+			"event":      m.Event,
+			"session_id": m.SessionID,
+		}
+		if m.UUID != "" {
+			raw["uuid"] = m.UUID
+		}
+		if m.ParentToolUseID != nil {
+			raw["parent_tool_use_id"] = *m.ParentToolUseID
+		}
+	default:
+		return nil, &ClaudeSDKError{Message: fmt.Sprintf("cannot encode message of type %T", message)}
+	}
+
+	raw["type"] = string(message.Type())
+	if timestamp := message.Timestamp(); !timestamp.IsZero() {
+		raw["timestamp"] = timestamp.Format(time.RFC3339Nano)
+	}
+	return raw, nil
+}
+
+func conversationMessageMap(role string, blocks []ContentBlock, sessionID string, parentToolUseID *string) map[string]interface{} {
+	content := make([]interface{}, 0, len(blocks))
+	for _, block := range blocks {
+		content = append(content, contentBlockToMap(block))
+	}
+	return map[string]interface{}{
+		"message":            map[string]interface{}{"role": role, "content": content},
+		"session_id":         sessionID,
+		"parent_tool_use_id": parentToolUseID,
+	}
+}
+
+func contentBlockToMap(block ContentBlock) map[string]interface{} {
+	switch b := block.(type) {
+	case *TextBlock:
+		return map[string]interface{}{"type": "text", "text": b.Text}
+	case *ThinkingBlock:
+		raw := map[string]interface{}{"type": "thinking", "thinking": b.Thinking}
+		if b.Signature != "" {
+			raw["signature"] = b.Signature
+		}
+		return raw
+	case *ToolUseBlock:
+		return map[string]interface{}{"type": "tool_use", "id": b.ID, "name": b.Name, "input": b.Input}
+	case *ToolResultBlock:
+		raw := map[string]interface{}{"type": "tool_result", "tool_use_id": b.ToolUseID, "content": b.Content}
+		if b.IsError {
+			raw["is_error"] = true
+		}
+		return raw
+	case *RawBlock:
+		return b.Data
+	}
+	return map[string]interface{}{"type": string(block.Type())}
+}
@@ -0,0 +1,42 @@
+package claudecode
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarshalMessageRoundTrip(t *testing.T) {
+	timestamp := time.Date(2025, 1, 2, 10, 0, 0, 500000000, time.UTC)
+	parent := "toolu_task"
+	model := "claude-sonnet-4-5"
+	cost := 0.25
+	result := "done"
+	messages := []Message{
+		&SystemMessage{Subtype: "init", SessionID: "abc", Model: &model, Tools: []string{"Read"}, CreatedAt: timestamp},
+		&AssistantMessage{SessionID: "abc", ParentToolUseID: &parent, CreatedAt: timestamp, ContentBlocks: []ContentBlock{
+			&TextBlock{Text: "Looking"},
+			&ThinkingBlock{Thinking: "hmm", Signature: "sig"},
+			&ToolUseBlock{ID: "toolu_1", Name: ToolRead, Input: map[string]interface{}{"file_path": "a.go"}},
+		}},
+		&UserMessage{SessionID: "abc", CreatedAt: timestamp, ToolUseResult: "ok", ContentBlocks: []ContentBlock{
+			&ToolResultBlock{ToolUseID: "toolu_1", Content: "package a", IsError: true},
+		}},
+		&ResultMessage{Subtype: "success", SessionID: "abc", NumTurns: 2, DurationMs: 1500, TotalCostUSD: &cost,
+			Usage: &Usage{InputTokens: 10, OutputTokens: 20}, Result: &result, CreatedAt: timestamp},
+	}
+
+	for _, message := range messages {
+		data, err := MarshalMessage(message)
+		if err != nil {
+			t.Fatalf("MarshalMessage(%T) failed: %v", message, err)
+		}
+		decoded, err := UnmarshalMessage(data)
+		if err != nil {
+			t.Fatalf("UnmarshalMessage(%s) failed: %v", data, err)
+		}
+		if !reflect.DeepEqual(decoded, message) {
+			t.Errorf("Round trip mismatch:\n got: %#v\nwant: %#v\njson: %s", decoded, message, data)
+		}
+	}
+}
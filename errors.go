@@ -1,6 +1,10 @@
 package claudecode
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // ClaudeSDKError represents a general SDK error
 type ClaudeSDKError struct {
@@ -78,3 +82,132 @@ func (e *CLIJSONDecodeError) Error() string {
 func (e *CLIJSONDecodeError) Unwrap() error {
 	return e.Cause
 }
+
+// StallError is returned when the CLI stops making progress within
+// Options.IdleTimeout or Options.TurnTimeout
+type StallError struct {
+	Kind        StallKind
+	Timeout     time.Duration
+	LastMessage Message
+	StderrTail  string
+}
+
+func (e *StallError) Error() string {
+	var msg string
+	if e.Kind == StallKindTurn {
+		msg = fmt.Sprintf("CLI stalled: turn did not complete within %s", e.Timeout)
+	} else {
+		msg = fmt.Sprintf("CLI stalled: no output for %s", e.Timeout)
+	}
+	if e.LastMessage != nil {
+		msg += fmt.Sprintf(" (last message: %s)", e.LastMessage.Type())
+	}
+	return msg
+}
+
+// StructuredOutputError is returned by QueryJSON when the final result does not
+// conform to the requested schema after all repair attempts
+type StructuredOutputError struct {
+	Result   string
+	Problems []string
+	Attempts int
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("structured output invalid after %d attempt(s): %s", e.Attempts, strings.Join(e.Problems, "; "))
+}
+
+// UnknownToolError is returned when decoding input or results of a tool
+// that has no typed representation, such as an MCP tool
+type UnknownToolError struct {
+	Name string
+}
+
+func (e *UnknownToolError) Error() string {
+	return fmt.Sprintf("no typed representation for tool: %s", e.Name)
+}
+
+// InvalidPermissionRuleError is returned for AllowedTools or DisallowedTools
+// entries the CLI would not apply as intended
+type InvalidPermissionRuleError struct {
+	Rule   string
+	Reason string
+}
+
+func (e *InvalidPermissionRuleError) Error() string {
+	return fmt.Sprintf("invalid permission rule %q: %s", e.Rule, e.Reason)
+}
+
+// InvalidOptionError is returned by Options.Validate for an invalid or conflicting option
+type InvalidOptionError struct {
+	Field  string
+	Reason string
+}
+
+func (e *InvalidOptionError) Error() string {
+	return fmt.Sprintf("invalid option %s: %s", e.Field, e.Reason)
+}
+
+// MCPInspectionError is reported by InspectMCPServers when a server fails
+// to start or to complete the MCP handshake
+type MCPInspectionError struct {
+	Server string
+	Stage  string
+	Cause  error
+	Stderr string
+}
+
+func (e *MCPInspectionError) Error() string {
+	return fmt.Sprintf("MCP server %s failed during %s: %v", e.Server, e.Stage, e.Cause)
+}
+
+func (e *MCPInspectionError) Unwrap() error {
+	return e.Cause
+}
+
+// SessionNotFoundError is returned when no transcript exists for a session ID
+type SessionNotFoundError struct {
+	ID string
+}
+
+func (e *SessionNotFoundError) Error() string {
+	return fmt.Sprintf("session not found: %s", e.ID)
+}
+
+// RateLimitError is returned when the CLI fails because of an API rate or usage
+// limit. Cause holds the underlying *ProcessError, if any.
+type RateLimitError struct {
+	Message string
+	// RetryAfter is how long to wait before retrying, when the CLI reported it
+	RetryAfter time.Duration
+	Cause      error
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited (retry after %s): %s", e.RetryAfter.Round(time.Second), e.Message)
+	}
+	return fmt.Sprintf("rate limited: %s", e.Message)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Cause
+}
+
+// CircuitOpenError is returned by a CircuitBreaker while its circuit is open.
+// The CLI is not launched.
+type CircuitOpenError struct {
+	// Failures is the number of consecutive failures that opened the circuit
+	Failures int
+	// RetryAt is when the next query will probe the CLI
+	RetryAt time.Time
+	// LastError is the failure that opened the circuit or the failed probe
+	LastError error
+}
+
+func (e *CircuitOpenError) Error() string {
+	if e.LastError != nil {
+		return fmt.Sprintf("circuit open after %d consecutive CLI failures (last error: %v)", e.Failures, e.LastError)
+	}
+	return fmt.Sprintf("circuit open after %d consecutive CLI failures", e.Failures)
+}
@@ -2,6 +2,6 @@ module github.com/kannae97/claude-code-sdk-go/examples
 
 go 1.21.4
 
-replace (
-	github.com/kannae97/claude-code-sdk-go => ../
-)
+replace github.com/kannae97/claude-code-sdk-go => ../
+
+require github.com/kannae97/claude-code-sdk-go v0.0.0-00010101000000-000000000000
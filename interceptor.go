@@ -0,0 +1,64 @@
+package claudecode
+
+import "context"
+
+// QueryHandler runs a query, passing each message to emit in order. An error
+// returned by emit stops the query and is returned.
+type QueryHandler func(ctx context.Context, prompt string, options *Options, emit func(Message) error) error
+
+// Interceptor wraps a query. It may change the prompt or options before calling
+// next, wrap emit to observe, transform or drop messages, inspect the error next
+// returns, or answer without calling next at all, e.g. from a cache. Options
+// are shared with the caller, so change a copy rather than the original.
+//
+// Interceptors run for Query and QueryStream (and everything built on them) in
+// the order of Options.Interceptors, the first being outermost. Options
+// validation, the rate limiter and the CLI run inside the innermost one.
+type Interceptor func(next QueryHandler) QueryHandler
+
+// chainInterceptors wraps handler in interceptors, the first being outermost
+func chainInterceptors(interceptors []Interceptor, handler QueryHandler) QueryHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		handler = interceptors[i](handler)
+	}
+	return handler
+}
+
+// queryHandler is the innermost handler of Query
+func queryHandler(ctx context.Context, prompt string, options *Options, emit func(Message) error) error {
+	if options == nil {
+		options = &Options{}
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	finish, err := waitForRateLimiter(ctx, options)
+	if err != nil {
+		return err
+	}
+	messages, err := runQuery(ctx, prompt, options, emit)
+	return finish(messages, err)
+}
+
+// streamHandler is the innermost handler of QueryStream
+func streamHandler(ctx context.Context, prompt string, options *Options, emit func(Message) error) error {
+	if options == nil {
+		options = &Options{}
+	}
+	if err := options.validateForStreaming(); err != nil {
+		return err
+	}
+	finish, err := waitForRateLimiter(ctx, options)
+	if err != nil {
+		return err
+	}
+
+	var results []Message
+	err = runQueryStream(ctx, prompt, options, func(message Message) error {
+		if result, ok := message.(*ResultMessage); ok {
+			results = []Message{result}
+		}
+		return emit(message)
+	})
+	return finish(results, err)
+}
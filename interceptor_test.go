@@ -0,0 +1,107 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// tracingInterceptor records when it is entered and left
+func tracingInterceptor(name string, calls *[]string) Interceptor {
+	return func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, prompt string, options *Options, emit func(Message) error) error {
+			*calls = append(*calls, name+">")
+			err := next(ctx, prompt, options, emit)
+			*calls = append(*calls, "<"+name)
+			return err
+		}
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	cli := writeFakeCLI(t, batchFakeCLI)
+	var calls []string
+	var finalErr error
+
+	rewrite := func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, prompt string, options *Options, emit func(Message) error) error {
+			return next(ctx, "fast", options, func(message Message) error {
+				if message.Type() == MessageTypeSystem {
+					return nil
+				}
+				if result, ok := message.(*ResultMessage); ok {
+					changed := *result
+					text := strings.ToUpper(*result.Result)
+					changed.Result = &text
+					return emit(&changed)
+				}
+				return emit(message)
+			})
+		}
+	}
+	inspect := func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, prompt string, options *Options, emit func(Message) error) error {
+			finalErr = next(ctx, prompt, options, emit)
+			return finalErr
+		}
+	}
+
+	options := &Options{
+		Executable:   &cli,
+		Interceptors: []Interceptor{tracingInterceptor("outer", &calls), rewrite, inspect, tracingInterceptor("inner", &calls)},
+	}
+	messages, err := Query(context.Background(), "original prompt", options)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(messages) != 1 || *messages[0].(*ResultMessage).Result != "DONE FAST" {
+		t.Errorf("Expected the rewritten prompt and transformed result, got %v", messages)
+	}
+	if strings.Join(calls, " ") != "outer> inner> <inner <outer" {
+		t.Errorf("Unexpected interceptor order: %v", calls)
+	}
+
+	calls = nil
+	stream, errs := QueryStream(context.Background(), "original prompt", options)
+	var streamed []Message
+	for message := range stream {
+		streamed = append(streamed, message)
+	}
+	for err := range errs {
+		t.Errorf("Unexpected stream error: %v", err)
+	}
+	if len(streamed) != 1 || len(calls) != 4 {
+		t.Errorf("Expected interceptors to apply to streams, got %v %v", streamed, calls)
+	}
+
+	invalid := PermissionMode("sometimes")
+	failing := *options
+	failing.PermissionMode = &invalid
+	if _, err := Query(context.Background(), "hi", &failing); err == nil || finalErr != err {
+		t.Errorf("Expected interceptors to see the validation error, got %v and %v", err, finalErr)
+	}
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	cached := &ResultMessage{Subtype: "success", Result: stringPtr("cached")}
+	cache := func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, prompt string, options *Options, emit func(Message) error) error {
+			if prompt == "blocked" {
+				return errors.New("prompt rejected by policy")
+			}
+			return emit(cached)
+		}
+	}
+	missing := "/nonexistent/claude"
+	options := &Options{Executable: &missing, Interceptors: []Interceptor{cache}}
+
+	messages, err := Query(context.Background(), "hi", options)
+	if err != nil || len(messages) != 1 || messages[0] != cached {
+		t.Errorf("Expected the cached answer without launching the CLI, got %v %v", messages, err)
+	}
+	_, errs := QueryStream(context.Background(), "blocked", options)
+	if err := <-errs; err == nil || err.Error() != "prompt rejected by policy" {
+		t.Errorf("Expected the policy error, got %v", err)
+	}
+}
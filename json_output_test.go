@@ -0,0 +1,116 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadJSONOutputSingleResult(t *testing.T) {
+	document := `{
+  "type": "result",
+  "subtype": "success",
+  "is_error": false,
+  "duration_ms": 1200,
+  "num_turns": 2,
+  "session_id": "abc",
+  "total_cost_usd": 0.01,
+  "result": "All done"
+}`
+
+	messages, err := readJSONOutput(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("readJSONOutput failed: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	result, ok := messages[0].(*ResultMessage)
+	if !ok {
+		t.Fatalf("Expected ResultMessage, got %T", messages[0])
+	}
+	if result.SessionID != "abc" || result.NumTurns != 2 || result.DurationMs != 1200 {
+		t.Errorf("Unexpected result fields: %+v", result)
+	}
+	if result.Result == nil || *result.Result != "All done" {
+		t.Errorf("Expected result text, got %v", result.Result)
+	}
+}
+
+func TestReadJSONOutputMessageArray(t *testing.T) {
+	document := `[
+  {"type": "system", "subtype": "init", "session_id": "abc", "tools": ["Read"]},
+  {
+    "type": "assistant",
+    "session_id": "abc",
+    "message": {"content": [{"type": "tool_use", "id": "t1", "name": "Read", "input": {"file_path": "go.mod"}}]}
+  },
+  {
+    "type": "user",
+    "session_id": "abc",
+    "message": {"content": [{"type": "tool_result", "tool_use_id": "t1", "content": "module x"}]}
+  },
+  {"type": "result", "subtype": "success", "session_id": "abc", "result": "It is module x"}
+]`
+
+	messages, err := readJSONOutput(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("readJSONOutput failed: %v", err)
+	}
+	expected := []MessageType{MessageTypeSystem, MessageTypeAssistant, MessageTypeUser, MessageTypeResult}
+	if len(messages) != len(expected) {
+		t.Fatalf("Expected %d messages, got %d", len(expected), len(messages))
+	}
+	for i, message := range messages {
+		if message.Type() != expected[i] {
+			t.Errorf("Message %d: expected %s, got %s", i, expected[i], message.Type())
+		}
+	}
+	toolUse, ok := messages[1].Content()[0].(*ToolUseBlock)
+	if !ok || toolUse.Input["file_path"] != "go.mod" {
+		t.Errorf("Unexpected tool use block: %+v", messages[1].Content()[0])
+	}
+}
+
+func TestReadJSONOutputInvalid(t *testing.T) {
+	for _, document := range []string{`{"type": "result"`, `"just a string"`, `[1, 2]`} {
+		if _, err := readJSONOutput(strings.NewReader(document)); err == nil {
+			t.Errorf("Expected error for %s", document)
+		} else if _, ok := err.(*CLIJSONDecodeError); !ok {
+			t.Errorf("Expected CLIJSONDecodeError for %s, got %T", document, err)
+		}
+	}
+}
+
+func TestQueryJSONOutputFormat(t *testing.T) {
+	cli := writeFakeCLI(t, `
+cat > /dev/null
+printf '{\n  "type": "result",\n  "subtype": "success",\n  "session_id": "abc",\n  "result": "4"\n}\n'
+`)
+	format := OutputFormatJSON
+	messages, err := Query(context.Background(), "What is 2+2?", &Options{Executable: &cli, OutputFormat: &format})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Type() != MessageTypeResult {
+		t.Fatalf("Expected a single result message, got %v", messages)
+	}
+}
+
+func TestQueryJSONOutputProcessError(t *testing.T) {
+	cli := writeFakeCLI(t, `
+cat > /dev/null
+echo "Invalid API key" >&2
+exit 2
+`)
+	format := OutputFormatJSON
+	_, err := Query(context.Background(), "hi", &Options{Executable: &cli, OutputFormat: &format})
+	var processErr *ProcessError
+	if !errors.As(err, &processErr) {
+		t.Fatalf("Expected ProcessError, got %T: %v", err, err)
+	}
+	if processErr.ExitCode != 2 || !strings.Contains(processErr.Stderr, "Invalid API key") {
+		t.Errorf("Expected the exit code and stderr of the CLI, got %+v", processErr)
+	}
+}
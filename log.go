@@ -0,0 +1,133 @@
+package claudecode
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	apiKeyPattern     = regexp.MustCompile(`sk-ant-[A-Za-z0-9_-]+`)
+	bearerPattern     = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+	secretJSONPattern = regexp.MustCompile(`(?i)("[a-z_-]*(?:api[_-]?key|token|secret|password|authorization)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+)
+
+// RedactSecrets masks API keys, bearer tokens and JSON fields named like
+// credentials. It is the default Options.RedactLog.
+func RedactSecrets(s string) string {
+	s = apiKeyPattern.ReplaceAllString(s, "sk-ant-[REDACTED]")
+	s = bearerPattern.ReplaceAllString(s, "${1}[REDACTED]")
+	return secretJSONPattern.ReplaceAllString(s, `$1"[REDACTED]"`)
+}
+
+// discardHandler is a slog.Handler that drops every record
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// queryLogger logs the lifecycle of one CLI process to Options.Logger. Raw
+// output, arguments and stderr pass through the redaction function first.
+type queryLogger struct {
+	*slog.Logger
+	redact    func(string) string
+	started   time.Time
+	sessionID string
+}
+
+// newQueryLogger returns a logger for options; it discards everything when
+// options has no Logger
+func newQueryLogger(options *Options) *queryLogger {
+	l := &queryLogger{Logger: slog.New(discardHandler{}), redact: RedactSecrets}
+	if options == nil {
+		return l
+	}
+	if options.Logger != nil {
+		l.Logger = options.Logger
+	}
+	if options.RedactLog != nil {
+		l.redact = options.RedactLog
+	}
+	return l
+}
+
+// with returns a copy of l that adds args to every record
+func (l *queryLogger) with(args ...any) *queryLogger {
+	copied := *l
+	copied.Logger = l.Logger.With(args...)
+	return &copied
+}
+
+// tagSession adds session_id to every later record of l, including the exit
+// record, the first time the session ID is known
+func (l *queryLogger) tagSession(sessionID string) {
+	if sessionID == "" || l.sessionID != "" {
+		return
+	}
+	l.sessionID = sessionID
+	l.Logger = l.Logger.With("session_id", sessionID)
+}
+
+// launching logs the command about to run
+func (l *queryLogger) launching(cmd *exec.Cmd) {
+	l.Debug("launching CLI",
+		"path", cmd.Path,
+		"args", l.redact(strings.Join(cmd.Args[1:], " ")),
+		"cwd", cmd.Dir)
+}
+
+// startedProcess records the start of cmd and returns a logger tagged with its pid
+func (l *queryLogger) startedProcess(cmd *exec.Cmd) *queryLogger {
+	started := l.with("pid", cmd.Process.Pid)
+	started.started = time.Now()
+	started.Debug("CLI started")
+	return started
+}
+
+// line logs a raw output line
+func (l *queryLogger) line(line string) {
+	if l.Enabled(context.Background(), slog.LevelDebug) {
+		l.Debug("CLI output", "line", l.redact(line))
+	}
+}
+
+// message logs a parsed message and its tool calls
+func (l *queryLogger) message(message Message) {
+	switch m := message.(type) {
+	case *ResultMessage:
+		attrs := []any{"message_type", m.Type(), "subtype", m.Subtype, "is_error", m.IsError, "num_turns", m.NumTurns, "duration_ms", m.DurationMs}
+		if m.TotalCostUSD != nil {
+			attrs = append(attrs, "cost_usd", *m.TotalCostUSD)
+		}
+		l.Info("query finished", attrs...)
+		return
+	case *SystemMessage:
+		l.Debug("message", "message_type", m.Type(), "subtype", m.Subtype)
+		return
+	}
+	l.Debug("message", "message_type", message.Type())
+	for _, block := range message.Content() {
+		if toolUse, ok := block.(*ToolUseBlock); ok {
+			l.Debug("tool call", "tool", toolUse.Name, "tool_use_id", toolUse.ID)
+		}
+	}
+}
+
+// exited logs how the process ended
+func (l *queryLogger) exited(exitCode int, stderr string) {
+	attrs := []any{"exit_code", exitCode}
+	if !l.started.IsZero() {
+		attrs = append(attrs, "duration", time.Since(l.started))
+	}
+	if exitCode != 0 {
+		attrs = append(attrs, "stderr", l.redact(strings.TrimSpace(stderr)))
+		l.Warn("CLI exited with error", attrs...)
+		return
+	}
+	l.Debug("CLI exited", attrs...)
+}
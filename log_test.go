@@ -0,0 +1,112 @@
+package claudecode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// decodeLogRecords parses the JSON log records written by a slog.JSONHandler
+func decodeLogRecords(t *testing.T, output string) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid log record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func findLogRecord(records []map[string]interface{}, msg string) map[string]interface{} {
+	for _, record := range records {
+		if record["msg"] == msg {
+			return record
+		}
+	}
+	return nil
+}
+
+func TestQueryLogging(t *testing.T) {
+	cli := writeFakeCLI(t, `
+cat >/dev/null
+echo '{"type":"system","subtype":"init","session_id":"s1"}'
+echo '{"type":"assistant","session_id":"s1","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"env","token":"sk-ant-secret123"}}]}}'
+echo '{"type":"result","subtype":"success","session_id":"s1","num_turns":1,"total_cost_usd":0.25,"result":"ok"}'
+`)
+	var out bytes.Buffer
+	options := &Options{
+		Executable:   &cli,
+		SystemPrompt: stringPtr("Use key sk-ant-abc123 when needed"),
+		Logger:       slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+	if _, err := Query(context.Background(), "hi", options); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	output := out.String()
+	if strings.Contains(output, "sk-ant-abc123") || strings.Contains(output, "sk-ant-secret123") {
+		t.Errorf("Expected secrets to be redacted, got %s", output)
+	}
+
+	records := decodeLogRecords(t, output)
+	launching := findLogRecord(records, "launching CLI")
+	if launching == nil || !strings.Contains(launching["args"].(string), "sk-ant-[REDACTED]") {
+		t.Errorf("Expected the redacted arguments to be logged, got %v", launching)
+	}
+	started := findLogRecord(records, "CLI started")
+	if started == nil || started["pid"] == nil {
+		t.Errorf("Expected the pid to be logged, got %v", started)
+	}
+	tool := findLogRecord(records, "tool call")
+	if tool == nil || tool["tool"] != "Bash" || tool["tool_use_id"] != "t1" || tool["session_id"] != "s1" || tool["pid"] == nil {
+		t.Errorf("Expected a tool call record with session and pid, got %v", tool)
+	}
+	finished := findLogRecord(records, "query finished")
+	if finished == nil || finished["level"] != "INFO" || finished["cost_usd"] != 0.25 {
+		t.Errorf("Expected the result to be logged at info level, got %v", finished)
+	}
+	exited := findLogRecord(records, "CLI exited")
+	if exited == nil || exited["exit_code"] != float64(0) || exited["session_id"] != "s1" {
+		t.Errorf("Expected the exit to be logged with the session, got %v", exited)
+	}
+	if lines := strings.Count(output, `"msg":"CLI output"`); lines != 3 {
+		t.Errorf("Expected 3 raw output lines, got %d", lines)
+	}
+}
+
+func TestQueryLoggingErrors(t *testing.T) {
+	cli := writeFakeCLI(t, `
+cat >/dev/null
+echo '{"type":"system","subtype":"init","session_id":"s2"}'
+echo '{"api_key": "abc"} Authorization: Bearer xyz.123' >&2
+exit 3
+`)
+	var out bytes.Buffer
+	options := &Options{
+		Executable: &cli,
+		Logger:     slog.New(slog.NewJSONHandler(&out, nil)),
+		RedactLog:  func(s string) string { return strings.ReplaceAll(RedactSecrets(s), "Authorization", "[HEADER]") },
+	}
+	messages, errs := QueryStream(context.Background(), "hi", options)
+	for range messages {
+	}
+	for range errs {
+	}
+
+	records := decodeLogRecords(t, out.String())
+	exited := findLogRecord(records, "CLI exited with error")
+	if exited == nil || exited["exit_code"] != float64(3) || exited["session_id"] != "s2" {
+		t.Fatalf("Expected the failed exit to be logged with the session, got %v", records)
+	}
+	if stderr := exited["stderr"]; stderr != `{"api_key": "[REDACTED]"} [HEADER]: Bearer [REDACTED]` {
+		t.Errorf("Expected stderr to pass through the redactor, got %q", stderr)
+	}
+	if findLogRecord(records, "CLI output") != nil {
+		t.Error("Expected raw lines only at debug level")
+	}
+}
@@ -0,0 +1,274 @@
+package claudecode
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// McpServerType identifies the transport of an MCP server
+type McpServerType string
+
+const (
+	McpServerTypeStdio McpServerType = "stdio"
+	McpServerTypeSSE   McpServerType = "sse"
+	McpServerTypeHTTP  McpServerType = "http"
+)
+
+// McpServerConfig is the configuration of a single MCP server, one of
+// McpStdioServerConfig, McpSSEServerConfig or McpHTTPServerConfig
+type McpServerConfig interface {
+	ServerType() McpServerType
+}
+
+// McpServerConfigs maps server names to their configuration. Unlike a plain
+// map of the McpServerConfig interface it can be decoded from JSON, so Options
+// and QueryRequest round-trip.
+type McpServerConfigs map[string]McpServerConfig
+
+// UnmarshalJSON decodes each server by its "type" field, defaulting to stdio
+func (c *McpServerConfigs) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		*c = nil
+		return nil
+	}
+	servers := make(McpServerConfigs, len(raw))
+	for name, rawServer := range raw {
+		config, err := parseMCPServerConfig(rawServer)
+		if err != nil {
+			return fmt.Errorf("invalid MCP server %q: %w", name, err)
+		}
+		servers[name] = config
+	}
+	*c = servers
+	return nil
+}
+
+// McpStdioServerConfig configures an MCP server launched as a subprocess
+type McpStdioServerConfig struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+}
+
+func (c McpStdioServerConfig) ServerType() McpServerType {
+	return McpServerTypeStdio
+}
+
+func (c McpStdioServerConfig) MarshalJSON() ([]byte, error) {
+	type config McpStdioServerConfig
+	return json.Marshal(struct {
+		Type McpServerType `json:"type"`
+		config
+	}{McpServerTypeStdio, config(c)})
+}
+
+// McpSSEServerConfig configures a remote MCP server using server-sent events
+type McpSSEServerConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+func (c McpSSEServerConfig) ServerType() McpServerType {
+	return McpServerTypeSSE
+}
+
+func (c McpSSEServerConfig) MarshalJSON() ([]byte, error) {
+	type config McpSSEServerConfig
+	return json.Marshal(struct {
+		Type McpServerType `json:"type"`
+		config
+	}{McpServerTypeSSE, config(c)})
+}
+
+// McpHTTPServerConfig configures a remote MCP server using streamable HTTP
+type McpHTTPServerConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+func (c McpHTTPServerConfig) ServerType() McpServerType {
+	return McpServerTypeHTTP
+}
+
+func (c McpHTTPServerConfig) MarshalJSON() ([]byte, error) {
+	type config McpHTTPServerConfig
+	return json.Marshal(struct {
+		Type McpServerType `json:"type"`
+		config
+	}{McpServerTypeHTTP, config(c)})
+}
+
+// LoadMCPConfig reads MCP servers from a .mcp.json style file
+func LoadMCPConfig(path string) (map[string]McpServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &ClaudeSDKError{
+			Message: fmt.Sprintf("failed to read MCP config %s", path),
+			Cause:   err,
+		}
+	}
+	return ParseMCPConfig(data)
+}
+
+// ParseMCPConfig parses the {"mcpServers": {...}} document used by .mcp.json
+// files and the --mcp-config flag. Like the CLI, it expands ${VAR} and
+// ${VAR:-default} in commands, arguments, environment values, URLs and headers.
+func ParseMCPConfig(data []byte) (map[string]McpServerConfig, error) {
+	var document struct {
+		MCPServers map[string]json.RawMessage `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, &CLIJSONDecodeError{
+			Data:  string(data),
+			Cause: err,
+		}
+	}
+
+	servers := make(map[string]McpServerConfig, len(document.MCPServers))
+	for name, raw := range document.MCPServers {
+		config, err := parseMCPServerConfig(raw)
+		if err != nil {
+			return nil, &ClaudeSDKError{
+				Message: fmt.Sprintf("invalid MCP server %q", name),
+				Cause:   err,
+			}
+		}
+		servers[name] = expandMCPServerEnv(config)
+	}
+	return servers, nil
+}
+
+func parseMCPServerConfig(raw json.RawMessage) (McpServerConfig, error) {
+	var header struct {
+		Type McpServerType `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, err
+	}
+
+	switch header.Type {
+	case McpServerTypeStdio, "":
+		var config McpStdioServerConfig
+		err := json.Unmarshal(raw, &config)
+		return config, err
+	case McpServerTypeSSE:
+		var config McpSSEServerConfig
+		err := json.Unmarshal(raw, &config)
+		return config, err
+	case McpServerTypeHTTP:
+		var config McpHTTPServerConfig
+		err := json.Unmarshal(raw, &config)
+		return config, err
+	}
+	return nil, fmt.Errorf("unknown server type %q", header.Type)
+}
+
+// mcpEnvPattern matches ${VAR} and ${VAR:-default}
+var mcpEnvPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandMCPEnv expands environment references in s. Unset variables without a
+// default are left as they are.
+func expandMCPEnv(s string) string {
+	return mcpEnvPattern.ReplaceAllStringFunc(s, func(reference string) string {
+		match := mcpEnvPattern.FindStringSubmatch(reference)
+		if value, ok := os.LookupEnv(match[1]); ok {
+			return value
+		}
+		if strings.Contains(reference, ":-") {
+			return match[2]
+		}
+		return reference
+	})
+}
+
+func expandMCPEnvMap(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	expanded := make(map[string]string, len(values))
+	for key, value := range values {
+		expanded[key] = expandMCPEnv(value)
+	}
+	return expanded
+}
+
+func expandMCPServerEnv(config McpServerConfig) McpServerConfig {
+	switch c := config.(type) {
+	case McpStdioServerConfig:
+		c.Command = expandMCPEnv(c.Command)
+		if c.Args != nil {
+			args := make([]string, len(c.Args))
+			for i, arg := range c.Args {
+				args[i] = expandMCPEnv(arg)
+			}
+			c.Args = args
+		}
+		c.Env = expandMCPEnvMap(c.Env)
+		return c
+	case McpSSEServerConfig:
+		c.URL = expandMCPEnv(c.URL)
+		c.Headers = expandMCPEnvMap(c.Headers)
+		return c
+	case McpHTTPServerConfig:
+		c.URL = expandMCPEnv(c.URL)
+		c.Headers = expandMCPEnvMap(c.Headers)
+		return c
+	}
+	return config
+}
+
+// MergeMCPServers merges server maps; servers in later maps replace
+// servers of the same name in earlier ones
+func MergeMCPServers(configs ...map[string]McpServerConfig) map[string]McpServerConfig {
+	merged := make(map[string]McpServerConfig)
+	for _, config := range configs {
+		for name, server := range config {
+			merged[name] = server
+		}
+	}
+	return merged
+}
+
+// validateMCPServer reports a configuration the CLI cannot start
+func validateMCPServer(config McpServerConfig) string {
+	switch c := config.(type) {
+	case McpStdioServerConfig:
+		if c.Command == "" {
+			return "stdio server requires a command"
+		}
+	case *McpStdioServerConfig:
+		return validateMCPServer(*c)
+	case McpSSEServerConfig:
+		if c.URL == "" {
+			return "sse server requires a url"
+		}
+	case *McpSSEServerConfig:
+		return validateMCPServer(*c)
+	case McpHTTPServerConfig:
+		if c.URL == "" {
+			return "http server requires a url"
+		}
+	case *McpHTTPServerConfig:
+		return validateMCPServer(*c)
+	case nil:
+		return "missing configuration"
+	}
+	return ""
+}
+
+func sortedServerNames(servers map[string]McpServerConfig) []string {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,334 @@
+package claudecode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mcpProtocolVersion is the MCP protocol revision requested during initialize
+const mcpProtocolVersion = "2025-06-18"
+
+// mcpInspectTimeout bounds the handshake with a single server
+const mcpInspectTimeout = 30 * time.Second
+
+// MCPServerStatus is the outcome of inspecting an MCP server
+type MCPServerStatus string
+
+const (
+	MCPServerStatusConnected MCPServerStatus = "connected"
+	MCPServerStatusFailed    MCPServerStatus = "failed"
+	// MCPServerStatusSkipped is reported for remote servers, which are not inspected
+	MCPServerStatusSkipped MCPServerStatus = "skipped"
+)
+
+// MCPTool describes a tool advertised by an MCP server
+type MCPTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+}
+
+// MCPServerReport is the result of inspecting one configured MCP server
+type MCPServerReport struct {
+	Name            string          `json:"name"`
+	Type            McpServerType   `json:"type"`
+	Status          MCPServerStatus `json:"status"`
+	ServerName      string          `json:"server_name,omitempty"`
+	ServerVersion   string          `json:"server_version,omitempty"`
+	ProtocolVersion string          `json:"protocol_version,omitempty"`
+	Tools           []MCPTool       `json:"tools,omitempty"`
+	Err             error           `json:"-"`
+}
+
+// ToolNames returns the tools in the mcp__server__tool form used by
+// MCPTools and AllowedTools
+func (r *MCPServerReport) ToolNames() []string {
+	names := make([]string, len(r.Tools))
+	for i, tool := range r.Tools {
+		names[i] = MCP(r.Name, tool.Name).String()
+	}
+	return names
+}
+
+// InspectMCPServers starts every stdio server configured through Options.MCPServers
+// and Options.MCPConfig, performs the MCP initialize and tools/list handshake and
+// reports the advertised tools. Servers are inspected concurrently and the reports
+// are sorted by server name; a failing server is reported with its error rather
+// than failing the whole inspection.
+func InspectMCPServers(ctx context.Context, options *Options) ([]MCPServerReport, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	servers, err := configuredMCPServers(options)
+	if err != nil {
+		return nil, err
+	}
+
+	names := sortedServerNames(servers)
+	reports := make([]MCPServerReport, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			reports[i] = inspectMCPServer(ctx, name, servers[name], options)
+		}(i, name)
+	}
+	wg.Wait()
+
+	return reports, nil
+}
+
+// MCPToolNames collects the tool names of every connected server
+func MCPToolNames(reports []MCPServerReport) []string {
+	var names []string
+	for i := range reports {
+		if reports[i].Status == MCPServerStatusConnected {
+			names = append(names, reports[i].ToolNames()...)
+		}
+	}
+	return names
+}
+
+// configuredMCPServers merges MCPConfig (a file path or JSON string) with
+// MCPServers. A relative path is resolved against Cwd, as the CLI does.
+func configuredMCPServers(options *Options) (map[string]McpServerConfig, error) {
+	var fromConfig map[string]McpServerConfig
+	if options.MCPConfig != nil && *options.MCPConfig != "" {
+		var err error
+		if strings.HasPrefix(strings.TrimSpace(*options.MCPConfig), "{") {
+			fromConfig, err = ParseMCPConfig([]byte(*options.MCPConfig))
+		} else {
+			path := *options.MCPConfig
+			if !filepath.IsAbs(path) && options.Cwd != nil && *options.Cwd != "" {
+				path = filepath.Join(*options.Cwd, path)
+			}
+			fromConfig, err = LoadMCPConfig(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return MergeMCPServers(fromConfig, options.MCPServers), nil
+}
+
+func inspectMCPServer(ctx context.Context, name string, config McpServerConfig, options *Options) MCPServerReport {
+	report := MCPServerReport{Name: name}
+	if config == nil {
+		report.Status = MCPServerStatusFailed
+		report.Err = &MCPInspectionError{Server: name, Stage: "configure", Cause: fmt.Errorf("missing configuration")}
+		return report
+	}
+	report.Type = config.ServerType()
+
+	var stdio McpStdioServerConfig
+	switch c := config.(type) {
+	case McpStdioServerConfig:
+		stdio = c
+	case *McpStdioServerConfig:
+		stdio = *c
+	default:
+		report.Status = MCPServerStatusSkipped
+		return report
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mcpInspectTimeout)
+	defer cancel()
+
+	client, err := startMCPClient(ctx, stdio, options)
+	if err != nil {
+		report.Status = MCPServerStatusFailed
+		report.Err = &MCPInspectionError{Server: name, Stage: "start", Cause: err}
+		return report
+	}
+	defer client.close()
+
+	fail := func(stage string, err error) MCPServerReport {
+		// Stop the server first so its stderr is complete
+		client.close()
+		report.Status = MCPServerStatusFailed
+		report.Err = &MCPInspectionError{Server: name, Stage: stage, Cause: err, Stderr: client.stderr.String()}
+		return report
+	}
+
+	var initResult struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	err = client.call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": "claude-code-sdk-go", "version": "1.0.0"},
+	}, &initResult)
+	if err != nil {
+		return fail("initialize", err)
+	}
+	report.ProtocolVersion = initResult.ProtocolVersion
+	report.ServerName = initResult.ServerInfo.Name
+	report.ServerVersion = initResult.ServerInfo.Version
+
+	if err := client.notify("notifications/initialized", nil); err != nil {
+		return fail("initialize", err)
+	}
+
+	var cursor string
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var listResult struct {
+			Tools      []MCPTool `json:"tools"`
+			NextCursor string    `json:"nextCursor"`
+		}
+		if err := client.call(ctx, "tools/list", params, &listResult); err != nil {
+			return fail("tools/list", err)
+		}
+		report.Tools = append(report.Tools, listResult.Tools...)
+		if listResult.NextCursor == "" || listResult.NextCursor == cursor {
+			break
+		}
+		cursor = listResult.NextCursor
+	}
+
+	report.Status = MCPServerStatusConnected
+	return report
+}
+
+// mcpClient speaks newline-delimited JSON-RPC 2.0 to a stdio MCP server
+type mcpClient struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  <-chan string
+	errs   <-chan error
+	done   chan struct{}
+	stderr *stderrTail
+	nextID int
+	closed sync.Once
+}
+
+func startMCPClient(ctx context.Context, config McpStdioServerConfig, options *Options) (*mcpClient, error) {
+	cmd := exec.CommandContext(ctx, config.Command, config.Args...)
+	if options.Cwd != nil {
+		cmd.Dir = *options.Cwd
+	}
+	cmd.Env = os.Environ()
+	for key, value := range config.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	stdin, stdout, stderr, err := createPipes(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	lines, errs := scanLines(stdout, done)
+	return &mcpClient{
+		cmd:    cmd,
+		stdin:  stdin,
+		lines:  lines,
+		errs:   errs,
+		done:   done,
+		stderr: captureStderr(stderr),
+	}, nil
+}
+
+type jsonRPCMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  interface{}      `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *jsonRPCError    `json:"error,omitempty"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (c *mcpClient) send(message jsonRPCMessage) error {
+	message.JSONRPC = "2.0"
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (c *mcpClient) notify(method string, params interface{}) error {
+	return c.send(jsonRPCMessage{Method: method, Params: params})
+}
+
+// call sends a request and waits for its response, answering any requests
+// the server makes in the meantime with a method-not-found error
+func (c *mcpClient) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	c.nextID++
+	id := json.RawMessage(fmt.Sprintf("%d", c.nextID))
+	if err := c.send(jsonRPCMessage{ID: &id, Method: method, Params: params}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case line, ok := <-c.lines:
+			if !ok {
+				select {
+				case err := <-c.errs:
+					return fmt.Errorf("reading server output: %w", err)
+				default:
+					return fmt.Errorf("server closed its output")
+				}
+			}
+			var response jsonRPCMessage
+			if err := json.Unmarshal([]byte(line), &response); err != nil {
+				// Servers sometimes log to stdout; ignore anything that is not JSON-RPC
+				continue
+			}
+			if response.ID == nil {
+				continue
+			}
+			if response.Method != "" {
+				_ = c.send(jsonRPCMessage{ID: response.ID, Error: &jsonRPCError{Code: -32601, Message: "method not found"}})
+				continue
+			}
+			if string(*response.ID) != string(id) {
+				continue
+			}
+			if response.Error != nil {
+				return fmt.Errorf("%s (code %d)", response.Error.Message, response.Error.Code)
+			}
+			if err := json.Unmarshal(response.Result, result); err != nil {
+				return &CLIJSONDecodeError{Data: string(response.Result), Cause: err}
+			}
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *mcpClient) close() {
+	c.closed.Do(func() {
+		close(c.done)
+		c.stdin.Close()
+		terminateCommand(c.cmd, c.stderr, newQueryLogger(nil))
+	})
+}
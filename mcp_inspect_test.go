@@ -0,0 +1,110 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const fakeMCPServer = `
+read -r line
+echo "server starting up"
+echo '{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"info"}}'
+echo '{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18","capabilities":{"tools":{}},"serverInfo":{"name":"fake","version":"0.1.0"}}}'
+read -r line
+read -r line
+echo '{"jsonrpc":"2.0","id":2,"result":{"tools":[{"name":"search","description":"Search docs","inputSchema":{"type":"object"}}],"nextCursor":"page2"}}'
+read -r line
+case "$line" in
+*page2*) echo '{"jsonrpc":"2.0","id":3,"result":{"tools":[{"name":"fetch"}]}}' ;;
+*) echo '{"jsonrpc":"2.0","id":3,"error":{"code":-32602,"message":"missing cursor"}}' ;;
+esac
+read -r line
+`
+
+func TestInspectMCPServers(t *testing.T) {
+	server := writeFakeCLI(t, fakeMCPServer)
+	broken := writeFakeCLI(t, "echo 'cannot connect to database' >&2\nexit 1\n")
+
+	reports, err := InspectMCPServers(context.Background(), &Options{
+		MCPConfig: stringPtr(`{"mcpServers": {"remote": {"type": "sse", "url": "https://example.com/sse"}}}`),
+		MCPServers: map[string]McpServerConfig{
+			"docs":   McpStdioServerConfig{Command: server},
+			"broken": &McpStdioServerConfig{Command: broken},
+		},
+	})
+	if err != nil {
+		t.Fatalf("InspectMCPServers failed: %v", err)
+	}
+	if len(reports) != 3 {
+		t.Fatalf("Expected 3 reports, got %d", len(reports))
+	}
+
+	brokenReport, docs, remote := reports[0], reports[1], reports[2]
+
+	if docs.Name != "docs" || docs.Status != MCPServerStatusConnected {
+		t.Fatalf("Expected docs to connect, got %+v", docs)
+	}
+	if docs.ServerName != "fake" || docs.ServerVersion != "0.1.0" || docs.ProtocolVersion != "2025-06-18" {
+		t.Errorf("Unexpected server info: %+v", docs)
+	}
+	if !reflect.DeepEqual(docs.ToolNames(), []string{"mcp__docs__search", "mcp__docs__fetch"}) {
+		t.Errorf("Unexpected tools: %v", docs.ToolNames())
+	}
+	if docs.Tools[0].InputSchema["type"] != "object" {
+		t.Errorf("Expected input schema, got %v", docs.Tools[0].InputSchema)
+	}
+
+	if brokenReport.Status != MCPServerStatusFailed {
+		t.Fatalf("Expected broken server to fail, got %+v", brokenReport)
+	}
+	var inspectErr *MCPInspectionError
+	if !errors.As(brokenReport.Err, &inspectErr) || inspectErr.Stage != "initialize" {
+		t.Errorf("Expected initialize failure, got %v", brokenReport.Err)
+	}
+	if !strings.Contains(inspectErr.Stderr, "cannot connect to database") {
+		t.Errorf("Expected stderr in error, got %q", inspectErr.Stderr)
+	}
+
+	if remote.Status != MCPServerStatusSkipped || remote.Type != McpServerTypeSSE {
+		t.Errorf("Expected remote server to be skipped, got %+v", remote)
+	}
+
+	if !reflect.DeepEqual(MCPToolNames(reports), []string{"mcp__docs__search", "mcp__docs__fetch"}) {
+		t.Errorf("Unexpected tool names: %v", MCPToolNames(reports))
+	}
+}
+
+func TestInspectMCPServersInvalidConfig(t *testing.T) {
+	_, err := InspectMCPServers(context.Background(), &Options{MCPConfig: stringPtr("/nonexistent/.mcp.json")})
+	if err == nil {
+		t.Error("Expected error for missing MCP config file")
+	}
+}
+
+func TestInspectMCPServersLargeToolList(t *testing.T) {
+	server := writeFakeCLI(t, `
+read -r line
+echo '{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18","capabilities":{"tools":{}},"serverInfo":{"name":"big","version":"1.0"}}}'
+read -r line
+read -r line
+description=$(head -c 200000 /dev/zero | tr '\0' x)
+echo '{"jsonrpc":"2.0","id":2,"result":{"tools":[{"name":"huge","description":"'"$description"'"}]}}'
+read -r line
+`)
+
+	reports, err := InspectMCPServers(context.Background(), &Options{
+		MCPServers: McpServerConfigs{"big": McpStdioServerConfig{Command: server}},
+	})
+	if err != nil {
+		t.Fatalf("InspectMCPServers failed: %v", err)
+	}
+	if reports[0].Status != MCPServerStatusConnected || len(reports[0].Tools) != 1 {
+		t.Fatalf("Expected a tool list larger than 64KB to be read, got %+v", reports[0].Err)
+	}
+	if len(reports[0].Tools[0].Description) != 200000 {
+		t.Errorf("Expected the full description, got %d bytes", len(reports[0].Tools[0].Description))
+	}
+}
@@ -0,0 +1,198 @@
+package claudecode
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMCPServerConfigJSON(t *testing.T) {
+	servers := map[string]McpServerConfig{
+		"filesystem": McpStdioServerConfig{
+			Command: "npx",
+			Args:    []string{"-y", "@modelcontextprotocol/server-filesystem", "/tmp"},
+			Env:     map[string]string{"DEBUG": "1"},
+		},
+		"events": &McpSSEServerConfig{URL: "https://example.com/sse"},
+		"api": McpHTTPServerConfig{
+			URL:     "https://example.com/mcp",
+			Headers: map[string]string{"Authorization": "Bearer token"},
+		},
+	}
+
+	options, remove, err := prepareMCPServers(&Options{MCPServers: servers})
+	if err != nil {
+		t.Fatalf("prepareMCPServers failed: %v", err)
+	}
+	args := addMCPArgs(nil, options)
+	if len(args) != 2 || args[0] != "--mcp-config" {
+		t.Fatalf("Unexpected args: %v", args)
+	}
+	info, err := os.Stat(args[1])
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("Expected a private config file, got %v %v", info, err)
+	}
+	data, err := os.ReadFile(args[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	remove()
+	if _, err := os.Stat(args[1]); !os.IsNotExist(err) {
+		t.Errorf("Expected the config file to be removed, got %v", err)
+	}
+
+	var document map[string]map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatalf("Invalid --mcp-config JSON: %v", err)
+	}
+	emitted := document["mcpServers"]
+	expected := map[string]map[string]interface{}{
+		"filesystem": {
+			"type":    "stdio",
+			"command": "npx",
+			"args":    []interface{}{"-y", "@modelcontextprotocol/server-filesystem", "/tmp"},
+			"env":     map[string]interface{}{"DEBUG": "1"},
+		},
+		"events": {"type": "sse", "url": "https://example.com/sse"},
+		"api": {
+			"type":    "http",
+			"url":     "https://example.com/mcp",
+			"headers": map[string]interface{}{"Authorization": "Bearer token"},
+		},
+	}
+	for name, want := range expected {
+		if !reflect.DeepEqual(emitted[name], want) {
+			t.Errorf("%s: got %v, want %v", name, emitted[name], want)
+		}
+	}
+
+	// The emitted document must round-trip through the loader
+	parsed, err := ParseMCPConfig(data)
+	if err != nil {
+		t.Fatalf("ParseMCPConfig failed: %v", err)
+	}
+	if parsed["events"].ServerType() != McpServerTypeSSE || parsed["api"].ServerType() != McpServerTypeHTTP {
+		t.Errorf("Unexpected round-tripped types: %v", parsed)
+	}
+}
+
+func TestLoadAndMergeMCPConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".mcp.json")
+	content := `{
+  "mcpServers": {
+    "filesystem": {"command": "npx", "args": ["-y", "server-filesystem", "."]},
+    "remote": {"type": "http", "url": "https://old.example.com/mcp"}
+  }
+}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := LoadMCPConfig(path)
+	if err != nil {
+		t.Fatalf("LoadMCPConfig failed: %v", err)
+	}
+	filesystem, ok := project["filesystem"].(McpStdioServerConfig)
+	if !ok || filesystem.Command != "npx" || len(filesystem.Args) != 3 {
+		t.Errorf("Expected untyped entry to load as stdio server, got %#v", project["filesystem"])
+	}
+
+	merged := MergeMCPServers(project, map[string]McpServerConfig{
+		"remote": McpHTTPServerConfig{URL: "https://new.example.com/mcp"},
+	})
+	if len(merged) != 2 {
+		t.Fatalf("Expected 2 merged servers, got %d", len(merged))
+	}
+	if merged["remote"].(McpHTTPServerConfig).URL != "https://new.example.com/mcp" {
+		t.Errorf("Expected later config to win, got %v", merged["remote"])
+	}
+
+	if _, err := ParseMCPConfig([]byte(`{"mcpServers": {"x": {"type": "websocket"}}}`)); err == nil {
+		t.Error("Expected error for unknown server type")
+	}
+	if _, err := LoadMCPConfig(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestMCPServersJSONRoundTrip(t *testing.T) {
+	options := &Options{MCPServers: McpServerConfigs{
+		"filesystem": McpStdioServerConfig{Command: "npx", Args: []string{"server-filesystem"}},
+		"api":        McpHTTPServerConfig{URL: "https://example.com/mcp"},
+	}}
+	data, err := json.Marshal(QueryRequest{Prompt: "hi", Options: options})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var request QueryRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(request.Options.MCPServers, options.MCPServers) {
+		t.Errorf("Expected %v, got %v", options.MCPServers, request.Options.MCPServers)
+	}
+
+	if err := json.Unmarshal([]byte(`{"mcp_servers":{"x":{"type":"websocket"}}}`), &Options{}); err == nil {
+		t.Error("Expected error for unknown server type")
+	}
+}
+
+func TestParseMCPConfigExpandsEnv(t *testing.T) {
+	t.Setenv("MCP_TEST_TOKEN", "secret")
+	t.Setenv("MCP_TEST_DIR", "/srv")
+	servers, err := ParseMCPConfig([]byte(`{"mcpServers": {
+  "fs": {"command": "npx", "args": ["server-filesystem", "${MCP_TEST_DIR}/data"], "env": {"LEVEL": "${MCP_TEST_LEVEL:-info}"}},
+  "api": {"type": "http", "url": "${MCP_TEST_HOST:-https://example.com}/mcp", "headers": {"Authorization": "Bearer ${MCP_TEST_TOKEN}", "X-Other": "${MCP_TEST_UNSET}"}}
+}}`))
+	if err != nil {
+		t.Fatalf("ParseMCPConfig failed: %v", err)
+	}
+	fs := servers["fs"].(McpStdioServerConfig)
+	if fs.Args[1] != "/srv/data" || fs.Env["LEVEL"] != "info" {
+		t.Errorf("Unexpected stdio expansion: %+v", fs)
+	}
+	api := servers["api"].(McpHTTPServerConfig)
+	if api.URL != "https://example.com/mcp" || api.Headers["Authorization"] != "Bearer secret" {
+		t.Errorf("Unexpected http expansion: %+v", api)
+	}
+	if api.Headers["X-Other"] != "${MCP_TEST_UNSET}" {
+		t.Errorf("Expected unset variable to be kept, got %q", api.Headers["X-Other"])
+	}
+}
+
+func TestQueryPassesMCPServersInPrivateFile(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	configFile := filepath.Join(dir, "config")
+	cli := writeFakeCLI(t, `
+cat >/dev/null
+echo "$@" > `+argsFile+`
+while [ $# -gt 0 ]; do
+  if [ "$1" = "--mcp-config" ]; then cat "$2" > `+configFile+`; echo "$2" >> `+argsFile+`; fi
+  shift
+done
+`+batchFakeCLI)
+	options := &Options{
+		Executable: &cli,
+		MCPServers: map[string]McpServerConfig{"api": McpHTTPServerConfig{URL: "https://example.com/mcp", Headers: map[string]string{"Authorization": "Bearer s3cret"}}},
+	}
+	if _, err := Query(context.Background(), "fast", options); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	args, _ := os.ReadFile(argsFile)
+	config, _ := os.ReadFile(configFile)
+	if strings.Contains(string(args), "s3cret") || !strings.Contains(string(config), "s3cret") {
+		t.Errorf("Expected the servers in a config file rather than argv, got args %q and config %q", args, config)
+	}
+	lines := strings.Split(strings.TrimSpace(string(args)), "\n")
+	if _, err := os.Stat(lines[len(lines)-1]); !os.IsNotExist(err) {
+		t.Errorf("Expected the config file to be removed after the query, got %v", err)
+	}
+}
@@ -0,0 +1,392 @@
+package claudecode
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMetricsBuckets are the histogram bucket bounds in seconds, from
+// process startup to long agent runs
+var DefaultMetricsBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+// Metrics collects counters and histograms about queries made through its
+// Interceptor and serves them in the Prometheus text exposition format:
+//
+//	claude_queries_started_total
+//	claude_queries_finished_total{result}             result is success or error
+//	claude_queries_failed_total{error_type}           queries that returned an error
+//	claude_query_duration_seconds
+//	claude_process_startup_seconds                    from launching the CLI to its first message
+//	claude_time_to_first_message_seconds              from the call to the first message
+//	claude_tool_calls_total{tool,is_error}
+//	claude_tool_call_duration_seconds{tool}
+//	claude_tokens_total{model,type}                   type is input or output
+//	claude_cost_usd_total{model}
+type Metrics struct {
+	mu sync.Mutex
+
+	started       *counterVec
+	finished      *counterVec
+	failed        *counterVec
+	queryDuration *histogramVec
+	startup       *histogramVec
+	firstMessage  *histogramVec
+	toolCalls     *counterVec
+	toolDuration  *histogramVec
+	tokens        *counterVec
+	cost          *counterVec
+	families      []metricFamily
+}
+
+// NewMetrics creates a collector using DefaultMetricsBuckets
+func NewMetrics() *Metrics {
+	return NewMetricsWithBuckets(DefaultMetricsBuckets)
+}
+
+// NewMetricsWithBuckets creates a collector with custom histogram bucket bounds in seconds
+func NewMetricsWithBuckets(buckets []float64) *Metrics {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	m := &Metrics{
+		started:       newCounterVec("claude_queries_started_total", "Queries started."),
+		finished:      newCounterVec("claude_queries_finished_total", "Queries that ended with a result message, by result.", "result"),
+		failed:        newCounterVec("claude_queries_failed_total", "Queries that returned an error, by error type.", "error_type"),
+		queryDuration: newHistogramVec("claude_query_duration_seconds", "Duration of queries.", buckets),
+		startup:       newHistogramVec("claude_process_startup_seconds", "Time from launching the CLI to its first message.", buckets),
+		firstMessage:  newHistogramVec("claude_time_to_first_message_seconds", "Time from starting a query to its first message.", buckets),
+		toolCalls:     newCounterVec("claude_tool_calls_total", "Tool calls with a result, by tool.", "tool", "is_error"),
+		toolDuration:  newHistogramVec("claude_tool_call_duration_seconds", "Time from a tool call to its result, by tool.", buckets, "tool"),
+		tokens:        newCounterVec("claude_tokens_total", "Tokens used, by model and type.", "model", "type"),
+		cost:          newCounterVec("claude_cost_usd_total", "Cost in USD, by model.", "model"),
+	}
+	m.families = []metricFamily{
+		m.started, m.finished, m.failed, m.queryDuration, m.startup,
+		m.firstMessage, m.toolCalls, m.toolDuration, m.tokens, m.cost,
+	}
+	return m
+}
+
+// Interceptor records the metrics of each query it wraps
+func (m *Metrics) Interceptor() Interceptor {
+	return func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, prompt string, options *Options, emit func(Message) error) error {
+			query := &queryMetrics{
+				metrics:  m,
+				started:  time.Now(),
+				model:    "unknown",
+				tools:    make(map[string]toolStart),
+				launched: make(chan time.Time, 1),
+			}
+			if options != nil && options.Model != nil {
+				query.model = *options.Model
+			}
+			m.mu.Lock()
+			m.started.add(1)
+			m.mu.Unlock()
+
+			ctx = withProcessStartHook(ctx, func(at time.Time) {
+				select {
+				case query.launched <- at:
+				default:
+				}
+			})
+			err := next(ctx, prompt, options, func(message Message) error {
+				query.observe(message)
+				return emit(message)
+			})
+			query.finish(err)
+			return err
+		}
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.WriteText(w)
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WriteText(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	buffered := bufio.NewWriter(w)
+	for _, family := range m.families {
+		family.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+// metricsErrorType names the kind of error for claude_queries_failed_total
+func metricsErrorType(err error) string {
+	var (
+		rateLimitErr   *RateLimitError
+		circuitErr     *CircuitOpenError
+		notFoundErr    *CLINotFoundError
+		stallErr       *StallError
+		processErr     *ProcessError
+		connectionErr  *CLIConnectionError
+		decodeErr      *CLIJSONDecodeError
+		invalidOptErr  *InvalidOptionError
+		invalidRuleErr *InvalidPermissionRuleError
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.As(err, &rateLimitErr):
+		return "rate_limit"
+	case errors.As(err, &circuitErr):
+		return "circuit_open"
+	case errors.As(err, &notFoundErr):
+		return "cli_not_found"
+	case errors.As(err, &stallErr):
+		return "stall"
+	case errors.As(err, &processErr):
+		return "process"
+	case errors.As(err, &connectionErr):
+		return "connection"
+	case errors.As(err, &decodeErr):
+		return "decode"
+	case errors.As(err, &invalidOptErr), errors.As(err, &invalidRuleErr):
+		return "invalid_options"
+	}
+	return "other"
+}
+
+// queryMetrics tracks one query until it is recorded
+type queryMetrics struct {
+	metrics      *Metrics
+	started      time.Time
+	firstMessage time.Time
+	model        string
+	tools        map[string]toolStart
+	result       *ResultMessage
+	launched     chan time.Time
+}
+
+type toolStart struct {
+	name string
+	at   time.Time
+}
+
+func (q *queryMetrics) observe(message Message) {
+	now := time.Now()
+	if q.firstMessage.IsZero() {
+		q.firstMessage = now
+		q.metrics.mu.Lock()
+		q.metrics.firstMessage.observe(now.Sub(q.started).Seconds())
+		select {
+		case launched := <-q.launched:
+			q.metrics.startup.observe(now.Sub(launched).Seconds())
+		default:
+		}
+		q.metrics.mu.Unlock()
+	}
+
+	switch m := message.(type) {
+	case *SystemMessage:
+		if m.Subtype == "init" && m.Model != nil {
+			q.model = *m.Model
+		}
+	case *ResultMessage:
+		q.result = m
+	}
+	for _, block := range message.Content() {
+		switch b := block.(type) {
+		case *ToolUseBlock:
+			q.tools[b.ID] = toolStart{name: b.Name, at: now}
+		case *ToolResultBlock:
+			start, ok := q.tools[b.ToolUseID]
+			if !ok {
+				continue
+			}
+			delete(q.tools, b.ToolUseID)
+			q.metrics.mu.Lock()
+			q.metrics.toolCalls.add(1, start.name, strconv.FormatBool(b.IsError))
+			q.metrics.toolDuration.observe(now.Sub(start.at).Seconds(), start.name)
+			q.metrics.mu.Unlock()
+		}
+	}
+}
+
+func (q *queryMetrics) finish(err error) {
+	m := q.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queryDuration.observe(time.Since(q.started).Seconds())
+	if err != nil {
+		m.failed.add(1, metricsErrorType(err))
+	}
+	if q.result == nil {
+		return
+	}
+	if q.result.IsError {
+		m.finished.add(1, "error")
+	} else {
+		m.finished.add(1, "success")
+	}
+	if q.result.Usage != nil {
+		m.tokens.add(float64(q.result.Usage.InputTokens), q.model, "input")
+		m.tokens.add(float64(q.result.Usage.OutputTokens), q.model, "output")
+	}
+	if q.result.TotalCostUSD != nil {
+		m.cost.add(*q.result.TotalCostUSD, q.model)
+	}
+}
+
+type processStartHookKey struct{}
+
+// withProcessStartHook returns a context asking the SDK to call hook when it launches the CLI
+func withProcessStartHook(ctx context.Context, hook func(time.Time)) context.Context {
+	return context.WithValue(ctx, processStartHookKey{}, hook)
+}
+
+// notifyProcessStart calls the hook set by withProcessStartHook, if any
+func notifyProcessStart(ctx context.Context) {
+	if hook, ok := ctx.Value(processStartHookKey{}).(func(time.Time)); ok {
+		hook(time.Now())
+	}
+}
+
+// metricFamily is a metric with all its label combinations
+type metricFamily interface {
+	write(w *bufio.Writer)
+}
+
+type counterVec struct {
+	name, help string
+	labels     []string
+	values     map[string]float64
+	labelSets  map[string][]string
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64), labelSets: make(map[string][]string)}
+}
+
+// add increases the counter for labelValues. The caller holds Metrics.mu.
+func (c *counterVec) add(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.values[key] += value
+	c.labelSets[key] = labelValues
+}
+
+func (c *counterVec) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	if len(c.labels) == 0 {
+		fmt.Fprintf(w, "%s %s\n", c.name, formatMetricValue(c.values[""]))
+		return
+	}
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, c.labelSets[key], ""), formatMetricValue(c.values[key]))
+	}
+}
+
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	series     map[string]*histogram
+}
+
+type histogram struct {
+	labelValues []string
+	counts      []uint64 // per bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogram)}
+}
+
+// observe records value for labelValues. The caller holds Metrics.mu.
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	series := h.series[key]
+	if series == nil {
+		series = &histogram{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		series.counts[i]++
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *histogramVec) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	series := make([]*histogram, 0, len(keys))
+	for _, key := range keys {
+		series = append(series, h.series[key])
+	}
+	if len(h.labels) == 0 && len(series) == 0 {
+		// An unlabeled histogram is exported even before its first observation
+		series = append(series, &histogram{counts: make([]uint64, len(h.buckets))})
+	}
+	for _, series := range series {
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += series.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, series.labelValues, formatMetricValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, series.labelValues, "+Inf"), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, series.labelValues, ""), formatMetricValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, series.labelValues, ""), series.count)
+	}
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabels renders {name="value",...}, adding le when set
+func formatLabels(names, values []string, le string) string {
+	var parts []string
+	for i, name := range names {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(values[i])))
+	}
+	if le != "" {
+		parts = append(parts, fmt.Sprintf(`le="%s"`, le))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatMetricValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,109 @@
+package claudecode
+
+import (
+	"bufio"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const metricsFakeCLI = `
+cat >/dev/null
+echo '{"type":"system","subtype":"init","session_id":"s1","model":"sonnet"}'
+echo '{"type":"assistant","session_id":"s1","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"ls"}},{"type":"tool_use","id":"t2","name":"Read","input":{"file_path":"a.go"}}]}}'
+sleep 0.1
+echo '{"type":"user","session_id":"s1","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"a.go"},{"type":"tool_result","tool_use_id":"t2","content":"denied","is_error":true}]}}'
+echo '{"type":"result","subtype":"success","session_id":"s1","num_turns":2,"total_cost_usd":0.25,"usage":{"input_tokens":100,"output_tokens":40},"result":"ok"}'
+`
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	cli := writeFakeCLI(t, metricsFakeCLI)
+	options := &Options{Executable: &cli, Interceptors: []Interceptor{metrics.Interceptor()}}
+
+	if _, err := Query(context.Background(), "hi", options); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	stream, errs := QueryStream(context.Background(), "hi", options)
+	for range stream {
+	}
+	for err := range errs {
+		t.Fatalf("QueryStream failed: %v", err)
+	}
+	failing := writeFakeCLI(t, `cat >/dev/null; echo "not logged in" >&2; exit 1`)
+	failingOptions := *options
+	failingOptions.Executable = &failing
+	if _, err := Query(context.Background(), "hi", &failingOptions); err == nil {
+		t.Fatal("Expected the failing query to fail")
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", contentType)
+	}
+	series := make(map[string]string)
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, _ := strings.Cut(line, " ")
+		series[name] = value
+	}
+
+	expected := map[string]string{
+		`claude_queries_started_total`:                                    "3",
+		`claude_queries_finished_total{result="success"}`:                 "2",
+		`claude_queries_failed_total{error_type="process"}`:               "1",
+		`claude_query_duration_seconds_count`:                             "3",
+		`claude_process_startup_seconds_count`:                            "2",
+		`claude_time_to_first_message_seconds_count`:                      "2",
+		`claude_tool_calls_total{tool="Bash",is_error="false"}`:           "2",
+		`claude_tool_calls_total{tool="Read",is_error="true"}`:            "2",
+		`claude_tool_call_duration_seconds_count{tool="Bash"}`:            "2",
+		`claude_tool_call_duration_seconds_bucket{tool="Bash",le="+Inf"}`: "2",
+		`claude_tool_call_duration_seconds_bucket{tool="Bash",le="0.05"}`: "0",
+		`claude_tokens_total{model="sonnet",type="input"}`:                "200",
+		`claude_tokens_total{model="sonnet",type="output"}`:               "80",
+		`claude_cost_usd_total{model="sonnet"}`:                           "0.5",
+	}
+	for name, value := range expected {
+		if series[name] != value {
+			t.Errorf("Expected %s %s, got %q", name, value, series[name])
+		}
+	}
+}
+
+func TestMetricsTextFormat(t *testing.T) {
+	metrics := NewMetricsWithBuckets([]float64{2, 1})
+	metrics.tokens.add(5, `odd "model"`+"\n", "input")
+	metrics.queryDuration.observe(0.5)
+	metrics.queryDuration.observe(1.5)
+	metrics.queryDuration.observe(3)
+
+	var out strings.Builder
+	if err := metrics.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+	for _, line := range []string{
+		"# TYPE claude_query_duration_seconds histogram",
+		`claude_query_duration_seconds_bucket{le="1"} 1`,
+		`claude_query_duration_seconds_bucket{le="2"} 2`,
+		`claude_query_duration_seconds_bucket{le="+Inf"} 3`,
+		"claude_query_duration_seconds_sum 5",
+		`claude_tokens_total{model="odd \"model\"\n",type="input"} 5`,
+		`claude_process_startup_seconds_bucket{le="+Inf"} 0`,
+		"claude_queries_started_total 0",
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, text)
+		}
+	}
+	if strings.Contains(text, "claude_tool_call_duration_seconds_bucket") {
+		t.Error("Expected labeled histograms without observations to have no series")
+	}
+}
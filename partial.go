@@ -0,0 +1,302 @@
+package claudecode
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// StreamEventType represents the type of a raw API streaming event
+type StreamEventType string
+
+const (
+	StreamEventMessageStart      StreamEventType = "message_start"
+	StreamEventContentBlockStart StreamEventType = "content_block_start"
+	StreamEventContentBlockDelta StreamEventType = "content_block_delta"
+	StreamEventContentBlockStop  StreamEventType = "content_block_stop"
+	StreamEventMessageDelta      StreamEventType = "message_delta"
+	StreamEventMessageStop       StreamEventType = "message_stop"
+)
+
+// DeltaType represents the type of an incremental content block update
+type DeltaType string
+
+const (
+	DeltaTypeText      DeltaType = "text_delta"
+	DeltaTypeThinking  DeltaType = "thinking_delta"
+	DeltaTypeInputJSON DeltaType = "input_json_delta"
+	DeltaTypeSignature DeltaType = "signature_delta"
+)
+
+// StreamDelta represents an incremental update to a content block
+type StreamDelta struct {
+	Type DeltaType `json:"type"`
+	// Text is set for text_delta
+	Text string `json:"text,omitempty"`
+	// Thinking is set for thinking_delta
+	Thinking string `json:"thinking,omitempty"`
+	// PartialJSON is a fragment of a tool's input JSON, set for input_json_delta
+	PartialJSON string `json:"partial_json,omitempty"`
+	// Signature is set for signature_delta
+	Signature string `json:"signature,omitempty"`
+}
+
+// StreamEvent represents a partial message update emitted while the assistant is
+// still generating. It is only produced when Options.IncludePartialMessages is set.
+type StreamEvent struct {
+	UUID            string          `json:"uuid,omitempty"`
+	SessionID       string          `json:"session_id"`
+	ParentToolUseID *string         `json:"parent_tool_use_id,omitempty"`
+	EventType       StreamEventType `json:"event_type"`
+	// Index is the content block index for content_block_* events
+	Index int `json:"index"`
+	// ContentBlock is the initial block for content_block_start events, a
+	// *RawBlock when its type is not known to the SDK
+	ContentBlock ContentBlock `json:"content_block,omitempty"`
+	// Delta is the incremental update for content_block_delta events
+	Delta *StreamDelta `json:"delta,omitempty"`
+	// Event is the raw API event as emitted by the CLI
+	Event     map[string]interface{} `json:"event"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+func (m *StreamEvent) Type() MessageType {
+	return MessageTypeStreamEvent
+}
+
+func (m *StreamEvent) Content() []ContentBlock {
+	// Partial updates are exposed through Delta; the complete content arrives
+	// in the AssistantMessage that follows
+	return []ContentBlock{}
+}
+
+func (m *StreamEvent) Timestamp() time.Time {
+	return m.CreatedAt
+}
+
+func parseStreamEvent(rawMessage map[string]interface{}, sessionID string, parentToolUseIDPtr *string, timestamp time.Time) (Message, error) {
+	event, ok := rawMessage["event"].(map[string]interface{})
+	if !ok {
+		return nil, &CLIJSONDecodeError{
+			Data:  fmt.Sprintf("%v", rawMessage),
+			Cause: fmt.Errorf("missing event in stream_event message"),
+		}
+	}
+
+	eventType, _ := event["type"].(string)
+	index, _ := event["index"].(float64)
+	uuid, _ := rawMessage["uuid"].(string)
+
+	streamEvent := &StreamEvent{
+		UUID:            uuid,
+		SessionID:       sessionID,
+		ParentToolUseID: parentToolUseIDPtr,
+		EventType:       StreamEventType(eventType),
+		Index:           int(index),
+		Event:           event,
+		CreatedAt:       timestamp,
+	}
+
+	switch streamEvent.EventType {
+	case StreamEventContentBlockStart:
+		if rawBlock, ok := event["content_block"]; ok {
+			// A malformed block must not abort the stream; it stays in Event
+			if block, err := parseContentBlock(rawBlock); err == nil {
+				streamEvent.ContentBlock = block
+			}
+		}
+	case StreamEventContentBlockDelta:
+		streamEvent.Delta = parseStreamDelta(event)
+	}
+
+	return streamEvent, nil
+}
+
+func parseStreamDelta(event map[string]interface{}) *StreamDelta {
+	deltaMap, ok := event["delta"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	deltaType, _ := deltaMap["type"].(string)
+	text, _ := deltaMap["text"].(string)
+	thinking, _ := deltaMap["thinking"].(string)
+	partialJSON, _ := deltaMap["partial_json"].(string)
+	signature, _ := deltaMap["signature"].(string)
+	return &StreamDelta{
+		Type:        DeltaType(deltaType),
+		Text:        text,
+		Thinking:    thinking,
+		PartialJSON: partialJSON,
+		Signature:   signature,
+	}
+}
+
+// partialBlock holds a content block while its deltas are being applied
+type partialBlock struct {
+	block     ContentBlock
+	inputJSON []byte
+	// untyped is set for blocks of a type the SDK does not know; their deltas are ignored
+	untyped bool
+}
+
+// PartialMessageAccumulator rebuilds a complete AssistantMessage from StreamEvents.
+// Feed every StreamEvent to Add; when a message_stop event arrives, Add returns the
+// assembled message. The accumulator is then ready for the next message.
+type PartialMessageAccumulator struct {
+	blocks          map[int]*partialBlock
+	sessionID       string
+	parentToolUseID *string
+}
+
+// NewPartialMessageAccumulator creates an empty accumulator
+func NewPartialMessageAccumulator() *PartialMessageAccumulator {
+	return &PartialMessageAccumulator{blocks: make(map[int]*partialBlock)}
+}
+
+// Add applies a stream event. It returns the completed message on message_stop
+// and nil otherwise.
+func (a *PartialMessageAccumulator) Add(event *StreamEvent) (*AssistantMessage, error) {
+	a.sessionID = event.SessionID
+	a.parentToolUseID = event.ParentToolUseID
+
+	switch event.EventType {
+	case StreamEventMessageStart:
+		a.blocks = make(map[int]*partialBlock)
+
+	case StreamEventContentBlockStart:
+		a.blocks[event.Index] = &partialBlock{
+			block:   copyContentBlock(event.ContentBlock),
+			untyped: isRawBlock(event.ContentBlock),
+		}
+
+	case StreamEventContentBlockDelta:
+		if event.Delta == nil {
+			return nil, nil
+		}
+		return nil, a.applyDelta(event.Index, event.Delta)
+
+	case StreamEventContentBlockStop:
+		return nil, a.finishBlock(event.Index)
+
+	case StreamEventMessageStop:
+		message := a.Message()
+		message.CreatedAt = event.CreatedAt
+		a.blocks = make(map[int]*partialBlock)
+		return message, nil
+	}
+
+	return nil, nil
+}
+
+// Message returns a snapshot of the message assembled so far
+func (a *PartialMessageAccumulator) Message() *AssistantMessage {
+	indexes := make([]int, 0, len(a.blocks))
+	for index := range a.blocks {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	blocks := make([]ContentBlock, 0, len(indexes))
+	for _, index := range indexes {
+		if block := a.blocks[index].block; block != nil {
+			blocks = append(blocks, block)
+		}
+	}
+
+	return &AssistantMessage{
+		ContentBlocks:   blocks,
+		ParentToolUseID: a.parentToolUseID,
+		SessionID:       a.sessionID,
+		CreatedAt:       time.Now(),
+	}
+}
+
+func (a *PartialMessageAccumulator) applyDelta(index int, delta *StreamDelta) error {
+	partial, ok := a.blocks[index]
+	if !ok {
+		// Tolerate a missing content_block_start by inferring the block from the delta
+		partial = &partialBlock{}
+		a.blocks[index] = partial
+	}
+	if partial.untyped {
+		return nil
+	}
+
+	switch delta.Type {
+	case DeltaTypeText:
+		block, ok := partial.block.(*TextBlock)
+		if !ok {
+			block = &TextBlock{}
+			partial.block = block
+		}
+		block.Text += delta.Text
+
+	case DeltaTypeThinking, DeltaTypeSignature:
+		block, ok := partial.block.(*ThinkingBlock)
+		if !ok {
+			block = &ThinkingBlock{}
+			partial.block = block
+		}
+		block.Thinking += delta.Thinking
+		block.Signature += delta.Signature
+
+	case DeltaTypeInputJSON:
+		if _, ok := partial.block.(*ToolUseBlock); !ok {
+			return &CLIJSONDecodeError{
+				Data:  delta.PartialJSON,
+				Cause: fmt.Errorf("input_json_delta for non tool_use block at index %d", index),
+			}
+		}
+		partial.inputJSON = append(partial.inputJSON, delta.PartialJSON...)
+	}
+
+	return nil
+}
+
+func (a *PartialMessageAccumulator) finishBlock(index int) error {
+	partial, ok := a.blocks[index]
+	if !ok || len(partial.inputJSON) == 0 {
+		return nil
+	}
+	block, ok := partial.block.(*ToolUseBlock)
+	if !ok {
+		return nil
+	}
+
+	var input map[string]interface{}
+	if err := json.Unmarshal(partial.inputJSON, &input); err != nil {
+		return &CLIJSONDecodeError{
+			Data:  string(partial.inputJSON),
+			Cause: err,
+		}
+	}
+	block.Input = input
+	partial.inputJSON = nil
+	return nil
+}
+
+// isRawBlock reports whether block is missing or of a type the SDK does not model
+func isRawBlock(block ContentBlock) bool {
+	_, raw := block.(*RawBlock)
+	return block == nil || raw
+}
+
+// copyContentBlock copies a block so accumulating deltas never mutates the event
+func copyContentBlock(block ContentBlock) ContentBlock {
+	switch b := block.(type) {
+	case *TextBlock:
+		copied := *b
+		return &copied
+	case *ThinkingBlock:
+		copied := *b
+		return &copied
+	case *ToolUseBlock:
+		copied := *b
+		return &copied
+	case *ToolResultBlock:
+		copied := *b
+		return &copied
+	}
+	return block
+}
@@ -0,0 +1,168 @@
+package claudecode
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func parseTestMessage(t *testing.T, line string) Message {
+	t.Helper()
+	var rawMessage map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &rawMessage); err != nil {
+		t.Fatalf("Invalid test JSON: %v", err)
+	}
+	message, err := parseMessage(rawMessage)
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	return message
+}
+
+func TestParseStreamEvent(t *testing.T) {
+	message := parseTestMessage(t, `{"type":"stream_event","uuid":"u1","session_id":"s1","parent_tool_use_id":null,`+
+		`"event":{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"Hel"}}}`)
+
+	event, ok := message.(*StreamEvent)
+	if !ok {
+		t.Fatalf("Expected StreamEvent, got %T", message)
+	}
+	if event.Type() != MessageTypeStreamEvent {
+		t.Errorf("Expected MessageTypeStreamEvent, got %s", event.Type())
+	}
+	if event.EventType != StreamEventContentBlockDelta || event.Index != 2 {
+		t.Errorf("Unexpected event %s at index %d", event.EventType, event.Index)
+	}
+	if event.Delta == nil || event.Delta.Type != DeltaTypeText || event.Delta.Text != "Hel" {
+		t.Errorf("Unexpected delta: %+v", event.Delta)
+	}
+	if event.SessionID != "s1" || event.UUID != "u1" {
+		t.Errorf("Expected session and uuid to be parsed, got %s/%s", event.SessionID, event.UUID)
+	}
+}
+
+func TestPartialMessageAccumulator(t *testing.T) {
+	lines := []string{
+		`{"type":"stream_event","session_id":"s1","event":{"type":"message_start","message":{}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me "}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"check."}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_stop","index":0}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Reading "}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"the file"}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_stop","index":1}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"tool-1","name":"Read","input":{}}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"file_path\":"}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"/tmp/a.go\"}"}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_stop","index":2}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"message_delta","delta":{"stop_reason":"tool_use"}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"message_stop"}}`,
+	}
+
+	accumulator := NewPartialMessageAccumulator()
+	var completed *AssistantMessage
+	for i, line := range lines {
+		event := parseTestMessage(t, line).(*StreamEvent)
+		message, err := accumulator.Add(event)
+		if err != nil {
+			t.Fatalf("Add failed on event %d: %v", i, err)
+		}
+		if message != nil {
+			if i != len(lines)-1 {
+				t.Fatalf("Message completed early on event %d", i)
+			}
+			completed = message
+		}
+	}
+
+	if completed == nil {
+		t.Fatal("Expected a completed message on message_stop")
+	}
+	if completed.SessionID != "s1" {
+		t.Errorf("Expected session s1, got %s", completed.SessionID)
+	}
+	blocks := completed.Content()
+	if len(blocks) != 3 {
+		t.Fatalf("Expected 3 blocks, got %d", len(blocks))
+	}
+
+	thinking, ok := blocks[0].(*ThinkingBlock)
+	if !ok || thinking.Thinking != "Let me check." || thinking.Signature != "sig" {
+		t.Errorf("Unexpected thinking block: %+v", blocks[0])
+	}
+	text, ok := blocks[1].(*TextBlock)
+	if !ok || text.Text != "Reading the file" {
+		t.Errorf("Unexpected text block: %+v", blocks[1])
+	}
+	toolUse, ok := blocks[2].(*ToolUseBlock)
+	if !ok || toolUse.ID != "tool-1" || toolUse.Name != "Read" {
+		t.Fatalf("Unexpected tool use block: %+v", blocks[2])
+	}
+	if toolUse.Input["file_path"] != "/tmp/a.go" {
+		t.Errorf("Expected tool input to be rebuilt, got %v", toolUse.Input)
+	}
+}
+
+func TestPartialMessageAccumulatorInvalidInputJSON(t *testing.T) {
+	accumulator := NewPartialMessageAccumulator()
+	events := []*StreamEvent{
+		{EventType: StreamEventContentBlockStart, Index: 0, ContentBlock: &ToolUseBlock{ID: "t", Name: "Bash"}},
+		{EventType: StreamEventContentBlockDelta, Index: 0, Delta: &StreamDelta{Type: DeltaTypeInputJSON, PartialJSON: `{"command":`}},
+	}
+	for _, event := range events {
+		if _, err := accumulator.Add(event); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	_, err := accumulator.Add(&StreamEvent{EventType: StreamEventContentBlockStop, Index: 0})
+	if _, ok := err.(*CLIJSONDecodeError); !ok {
+		t.Errorf("Expected CLIJSONDecodeError for truncated input, got %T", err)
+	}
+}
+
+func TestPartialMessagesWithUnknownBlock(t *testing.T) {
+	lines := []string{
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_start","index":0,"content_block":{"type":"server_tool_use","id":"srv-1","name":"web_search","input":{}}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"query\":\"go\"}"}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_stop","index":0}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_start","index":1,"content_block":{"type":"text","text":"Found it"}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"message_stop"}}`,
+	}
+
+	accumulator := NewPartialMessageAccumulator()
+	var message *AssistantMessage
+	for i, line := range lines {
+		event := parseTestMessage(t, line).(*StreamEvent)
+		if i == 0 {
+			if raw, ok := event.ContentBlock.(*RawBlock); !ok || raw.Type() != "server_tool_use" || raw.Data["name"] != "web_search" {
+				t.Errorf("Expected a raw server_tool_use block, got %#v", event.ContentBlock)
+			}
+		}
+		var err error
+		if message, err = accumulator.Add(event); err != nil {
+			t.Fatalf("Unexpected error for %s: %v", line, err)
+		}
+	}
+	if message == nil || len(message.ContentBlocks) != 2 {
+		t.Fatalf("Expected the raw and text blocks, got %+v", message)
+	}
+	if _, ok := message.ContentBlocks[1].(*TextBlock); !ok {
+		t.Errorf("Expected a text block after the raw block, got %T", message.ContentBlocks[1])
+	}
+}
+
+func TestIncludePartialMessagesArg(t *testing.T) {
+	include := true
+	args := buildCommandArgs(&Options{IncludePartialMessages: &include})
+	found := false
+	for _, arg := range args {
+		if arg == "--include-partial-messages" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected --include-partial-messages in %v", args)
+	}
+}
@@ -0,0 +1,415 @@
+package claudecode
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// mcpToolPrefix prefixes the names of tools provided by MCP servers
+const mcpToolPrefix = "mcp__"
+
+// bashPrefixSuffix marks a Bash rule specifier as a command prefix
+const bashPrefixSuffix = ":*"
+
+// webFetchDomainPrefix marks a WebFetch rule specifier as a domain
+const webFetchDomainPrefix = "domain:"
+
+// PermissionRule is a single AllowedTools/DisallowedTools entry such as
+// "Read", "Bash(git diff:*)", "Edit(src/**)" or "mcp__github__create_issue"
+type PermissionRule struct {
+	// ToolName is the tool the rule applies to
+	ToolName string
+	// Specifier is the content between the parentheses, empty for the whole tool
+	Specifier string
+}
+
+// Tool starts a rule for a tool. Without further refinement it matches every use of the tool.
+func Tool(name string) PermissionRule {
+	return PermissionRule{ToolName: name}
+}
+
+// MCP creates a rule for a tool of an MCP server, or for all of its tools when tool is empty
+func MCP(server, tool string) PermissionRule {
+	name := mcpToolPrefix + server
+	if tool != "" {
+		name += "__" + tool
+	}
+	return PermissionRule{ToolName: name}
+}
+
+// Prefix restricts a Bash rule to commands starting with prefix
+func (r PermissionRule) Prefix(prefix string) PermissionRule {
+	r.Specifier = strings.TrimSpace(prefix) + bashPrefixSuffix
+	return r
+}
+
+// Exact restricts a Bash rule to exactly this command
+func (r PermissionRule) Exact(command string) PermissionRule {
+	r.Specifier = command
+	return r
+}
+
+// Path restricts a file tool rule to paths matching a gitignore-style pattern
+func (r PermissionRule) Path(pattern string) PermissionRule {
+	r.Specifier = pattern
+	return r
+}
+
+// Domain restricts a WebFetch rule to a host
+func (r PermissionRule) Domain(domain string) PermissionRule {
+	r.Specifier = webFetchDomainPrefix + domain
+	return r
+}
+
+// String formats the rule the way the CLI expects it
+func (r PermissionRule) String() string {
+	if r.Specifier == "" {
+		return r.ToolName
+	}
+	return r.ToolName + "(" + r.Specifier + ")"
+}
+
+// ParsePermissionRule parses and validates a rule string, rejecting tools the
+// SDK does not know
+func ParsePermissionRule(rule string) (PermissionRule, error) {
+	return parsePermissionRule(rule, true)
+}
+
+func parsePermissionRule(rule string, knownTools bool) (PermissionRule, error) {
+	trimmed := strings.TrimSpace(rule)
+	parsed := PermissionRule{ToolName: trimmed}
+
+	if open := strings.Index(trimmed, "("); open >= 0 {
+		if !strings.HasSuffix(trimmed, ")") {
+			return parsed, &InvalidPermissionRuleError{Rule: rule, Reason: "missing closing parenthesis"}
+		}
+		parsed.ToolName = trimmed[:open]
+		parsed.Specifier = trimmed[open+1 : len(trimmed)-1]
+	} else if strings.Contains(trimmed, ")") {
+		return parsed, &InvalidPermissionRuleError{Rule: rule, Reason: "missing opening parenthesis"}
+	}
+
+	return parsed, parsed.validate(knownTools)
+}
+
+// ParsePermissionRules parses a list of rules, reporting every invalid entry.
+// Entries may themselves be comma or space-separated lists. Tools the SDK does
+// not know are reported too; Options.Validate and NewPermissionPolicy accept
+// them, since the CLI gains tools faster than this list.
+func ParsePermissionRules(rules []string) ([]PermissionRule, error) {
+	return parsePermissionRules(rules, true)
+}
+
+func parsePermissionRules(rules []string, knownTools bool) ([]PermissionRule, error) {
+	parsed := make([]PermissionRule, 0, len(rules))
+	var errs []error
+	for _, entry := range rules {
+		for _, rule := range SplitPermissionRules(entry) {
+			permissionRule, err := parsePermissionRule(rule, knownTools)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			parsed = append(parsed, permissionRule)
+		}
+	}
+	return parsed, errors.Join(errs...)
+}
+
+// SplitPermissionRules splits a comma or space-separated list of rules, such as
+// "Read,Bash(git diff:*, git log:*) Edit", leaving separators inside
+// parentheses alone
+func SplitPermissionRules(list string) []string {
+	var rules []string
+	depth, start := 0, 0
+	for i, r := range list {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case depth == 0 && (r == ',' || r == ' ' || r == '\t' || r == '\n'):
+			if rule := list[start:i]; rule != "" {
+				rules = append(rules, rule)
+			}
+			start = i + 1
+		}
+	}
+	if rule := list[start:]; rule != "" {
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// Validate reports rules the CLI would silently ignore, including rules for
+// tools the SDK does not know
+func (r PermissionRule) Validate() error {
+	return r.validate(true)
+}
+
+func (r PermissionRule) validate(knownTools bool) error {
+	invalid := func(reason string) error {
+		return &InvalidPermissionRuleError{Rule: r.String(), Reason: reason}
+	}
+
+	if r.ToolName == "" {
+		return invalid("missing tool name")
+	}
+	if strings.ContainsAny(r.ToolName, " ,()") {
+		return invalid("tool name contains invalid characters")
+	}
+
+	if strings.HasPrefix(r.ToolName, mcpToolPrefix) {
+		if r.Specifier != "" {
+			return invalid("MCP rules do not take a specifier")
+		}
+		if strings.TrimPrefix(r.ToolName, mcpToolPrefix) == "" {
+			return invalid("missing MCP server name")
+		}
+		return nil
+	}
+
+	family := toolFamily(r.ToolName)
+	if family == "" {
+		if !knownTools {
+			return nil
+		}
+		return invalid("unknown tool " + r.ToolName)
+	}
+	if r.Specifier == "" {
+		return nil
+	}
+
+	switch family {
+	case ToolBash:
+		command := strings.TrimSuffix(r.Specifier, bashPrefixSuffix)
+		if strings.Contains(command, "*") {
+			return invalid("wildcards are only supported as a trailing \":*\" prefix marker, e.g. Bash(git:*)")
+		}
+		if strings.TrimSpace(command) == "" {
+			return invalid("empty Bash command")
+		}
+	case ToolWebFetch:
+		if !strings.HasPrefix(r.Specifier, webFetchDomainPrefix) {
+			return invalid("WebFetch rules must use the form WebFetch(domain:example.com)")
+		}
+		if strings.TrimPrefix(r.Specifier, webFetchDomainPrefix) == "" {
+			return invalid("empty WebFetch domain")
+		}
+	case ToolRead, ToolEdit:
+		if _, err := path.Match(strings.ReplaceAll(r.Specifier, "**", "*"), ""); err != nil {
+			return invalid("malformed path pattern")
+		}
+	default:
+		return invalid(r.ToolName + " rules do not take a specifier")
+	}
+	return nil
+}
+
+// toolFamily maps a built-in tool to the tool whose rule specifiers it shares:
+// Read rules also govern Glob, Grep and LS, and Edit rules govern every file-modifying tool
+func toolFamily(name string) string {
+	switch name {
+	case ToolRead, ToolGlob, ToolGrep, ToolLS:
+		return ToolRead
+	case ToolEdit, ToolWrite, ToolMultiEdit, ToolNotebookEdit:
+		return ToolEdit
+	case ToolBash, ToolWebFetch, ToolWebSearch, ToolTodoWrite, ToolTask, ToolExitPlanMode:
+		return name
+	}
+	return ""
+}
+
+// Matches reports whether the rule applies to a tool use. Relative path patterns
+// are resolved against baseDir (typically Options.Cwd); an empty baseDir uses
+// the process working directory.
+func (r PermissionRule) Matches(block *ToolUseBlock, baseDir string) bool {
+	if strings.HasPrefix(r.ToolName, mcpToolPrefix) {
+		return block.Name == r.ToolName ||
+			strings.HasPrefix(block.Name, strings.TrimSuffix(r.ToolName, "__*")+"__")
+	}
+
+	if r.Specifier == "" {
+		return block.Name == r.ToolName
+	}
+
+	family := toolFamily(r.ToolName)
+	if family != toolFamily(block.Name) {
+		return false
+	}
+	switch family {
+	case ToolBash:
+		command, _ := block.Input["command"].(string)
+		command = strings.TrimSpace(command)
+		if prefix, ok := strings.CutSuffix(r.Specifier, bashPrefixSuffix); ok {
+			return matchBashPrefix(command, prefix)
+		}
+		return command == r.Specifier
+	case ToolWebFetch:
+		rawURL, _ := block.Input["url"].(string)
+		parsed, err := url.Parse(rawURL)
+		if err != nil {
+			return false
+		}
+		return strings.EqualFold(parsed.Hostname(), strings.TrimPrefix(r.Specifier, webFetchDomainPrefix))
+	case ToolRead, ToolEdit:
+		target := toolTargetPath(block)
+		if target == "" {
+			return false
+		}
+		return matchPathPattern(r.Specifier, target, baseDir)
+	}
+	return false
+}
+
+// matchBashPrefix reports whether command runs the command prefix: the prefix
+// must end at a word boundary, and commands chaining or substituting other
+// commands never match, since the rule says nothing about those
+func matchBashPrefix(command, prefix string) bool {
+	if strings.ContainsAny(command, ";|&`\n\r") || strings.Contains(command, "$(") {
+		return false
+	}
+	rest, ok := strings.CutPrefix(command, prefix)
+	return ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t')
+}
+
+// toolTargetPath returns the file or directory a file tool operates on
+func toolTargetPath(block *ToolUseBlock) string {
+	for _, key := range []string{"file_path", "notebook_path", "path"} {
+		if value, ok := block.Input[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// matchPathPattern matches a path against a gitignore-style rule pattern:
+// "//abs" is absolute, "~/x" is relative to the home directory, "/x" and "./x"
+// are relative to baseDir, and patterns without a slash match at any depth
+func matchPathPattern(pattern, target, baseDir string) bool {
+	if baseDir == "" {
+		baseDir, _ = os.Getwd()
+	}
+	baseDir = filepath.ToSlash(baseDir)
+
+	switch {
+	case strings.HasPrefix(pattern, "//"):
+		pattern = pattern[1:]
+	case strings.HasPrefix(pattern, "~/"):
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return false
+		}
+		pattern = path.Join(filepath.ToSlash(home), pattern[2:])
+	case strings.HasPrefix(pattern, "/"):
+		pattern = path.Join(baseDir, pattern)
+	default:
+		pattern = strings.TrimPrefix(pattern, "./")
+		if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+			pattern = "**/" + pattern
+		}
+		pattern = path.Join(baseDir, pattern)
+	}
+
+	target = filepath.ToSlash(target)
+	if !path.IsAbs(target) {
+		target = path.Join(baseDir, target)
+	}
+	target = path.Clean(target)
+
+	// A pattern naming a directory also covers everything below it
+	if strings.HasSuffix(pattern, "/") || !strings.Contains(path.Base(pattern), "*") {
+		if matchGlobSegments(splitPath(strings.TrimSuffix(pattern, "/")+"/**"), splitPath(target)) {
+			return true
+		}
+	}
+	return matchGlobSegments(splitPath(pattern), splitPath(target))
+}
+
+func splitPath(p string) []string {
+	return strings.Split(strings.Trim(p, "/"), "/")
+}
+
+// matchGlobSegments matches path segments where "**" spans any number of segments
+func matchGlobSegments(pattern, target []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(target); i++ {
+				if matchGlobSegments(rest, target[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(target) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], target[0]); err != nil || !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		target = target[1:]
+	}
+	return len(target) == 0
+}
+
+// PermissionDecision is the outcome of evaluating a tool use against a policy
+type PermissionDecision string
+
+const (
+	// PermissionAllow means an allow rule matched and no deny rule did
+	PermissionAllow PermissionDecision = "allow"
+	// PermissionDeny means a deny rule matched
+	PermissionDeny PermissionDecision = "deny"
+	// PermissionAsk means no rule matched; the CLI falls back to its permission mode
+	PermissionAsk PermissionDecision = "ask"
+)
+
+// PermissionPolicy evaluates tool uses against AllowedTools and DisallowedTools
+// locally, so policies can be unit tested without running the CLI
+type PermissionPolicy struct {
+	Allow   []PermissionRule
+	Deny    []PermissionRule
+	BaseDir string
+}
+
+// NewPermissionPolicy builds a policy from the tool rules of options
+func NewPermissionPolicy(options *Options) (*PermissionPolicy, error) {
+	allow, allowErr := parsePermissionRules(options.AllowedTools, false)
+	deny, denyErr := parsePermissionRules(options.DisallowedTools, false)
+	policy := &PermissionPolicy{Allow: allow, Deny: deny}
+	if options.Cwd != nil {
+		policy.BaseDir = *options.Cwd
+	}
+	return policy, errors.Join(allowErr, denyErr)
+}
+
+// Decide evaluates a tool use. Deny rules take precedence over allow rules.
+// The matching rule is returned for allow and deny decisions.
+func (p *PermissionPolicy) Decide(block *ToolUseBlock) (PermissionDecision, *PermissionRule) {
+	for i := range p.Deny {
+		if p.Deny[i].Matches(block, p.BaseDir) {
+			return PermissionDeny, &p.Deny[i]
+		}
+	}
+	for i := range p.Allow {
+		if p.Allow[i].Matches(block, p.BaseDir) {
+			return PermissionAllow, &p.Allow[i]
+		}
+	}
+	return PermissionAsk, nil
+}
+
+// RuleStrings formats rules for AllowedTools or DisallowedTools
+func RuleStrings(rules ...PermissionRule) []string {
+	formatted := make([]string, len(rules))
+	for i, rule := range rules {
+		formatted[i] = rule.String()
+	}
+	return formatted
+}
@@ -0,0 +1,273 @@
+package claudecode
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultPoolConcurrency is used when NewPool is given a non-positive limit
+const defaultPoolConcurrency = 4
+
+// Priority orders queued pool queries; higher priorities run first
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+type poolKeyContextKey struct{}
+type priorityContextKey struct{}
+
+// WithPoolKey tags queries made with ctx with a fairness key, such as a tenant
+// or project. A Pool alternates between keys so that one busy key cannot starve
+// the others.
+func WithPoolKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, poolKeyContextKey{}, key)
+}
+
+// WithPriority sets the priority of pool queries made with ctx
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityContextKey{}, priority)
+}
+
+// PoolStats is a snapshot of a pool's load and queue times
+type PoolStats struct {
+	MaxConcurrent int `json:"max_concurrent"`
+	Running       int `json:"running"`
+	Queued        int `json:"queued"`
+	// QueuedByKey counts queued queries per fairness key
+	QueuedByKey map[string]int `json:"queued_by_key"`
+	// Started counts queries that obtained a slot
+	Started int64 `json:"started"`
+	// Abandoned counts queries whose context ended while queued
+	Abandoned      int64         `json:"abandoned"`
+	TotalQueueTime time.Duration `json:"total_queue_time"`
+	MaxQueueTime   time.Duration `json:"max_queue_time"`
+}
+
+// AverageQueueTime is the mean time started queries spent queued
+func (s PoolStats) AverageQueueTime() time.Duration {
+	if s.Started == 0 {
+		return 0
+	}
+	return s.TotalQueueTime / time.Duration(s.Started)
+}
+
+// Pool limits the number of CLI processes running at once. Queries beyond the
+// limit wait in a queue ordered by priority (see WithPriority) and, within a
+// priority, served round-robin across fairness keys (see WithPoolKey). Query and
+// QueryStream have the same signatures as the package functions.
+type Pool struct {
+	mu      sync.Mutex
+	max     int
+	running int
+	levels  map[Priority]*fairQueue
+	stats   PoolStats
+}
+
+// poolWaiter is a query waiting for a slot
+type poolWaiter struct {
+	key      string
+	queued   time.Time
+	ready    chan struct{}
+	granted  bool
+	canceled bool
+}
+
+// fairQueue holds the waiters of one priority as a FIFO per key, visited round-robin
+type fairQueue struct {
+	keys   []string
+	queues map[string][]*poolWaiter
+}
+
+// NewPool creates a pool running at most maxConcurrent queries at once
+// (4 when maxConcurrent is not positive)
+func NewPool(maxConcurrent int) *Pool {
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultPoolConcurrency
+	}
+	return &Pool{max: maxConcurrent, levels: make(map[Priority]*fairQueue)}
+}
+
+// Query runs Query once a slot is available
+func (p *Pool) Query(ctx context.Context, prompt string, options *Options) ([]Message, error) {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return Query(ctx, prompt, options)
+}
+
+// QueryStream runs QueryStream once a slot is available. The slot is held until
+// both returned channels are closed, or until ctx is done even if the caller
+// stops reading.
+func (p *Pool) QueryStream(ctx context.Context, prompt string, options *Options) (<-chan Message, <-chan error) {
+	messageChan := make(chan Message, 10)
+	errorChan := make(chan error, 1)
+
+	go func() {
+		defer close(messageChan)
+		defer close(errorChan)
+
+		release, err := p.acquire(ctx)
+		if err != nil {
+			errorChan <- err
+			return
+		}
+		defer release()
+
+		messages, errs := QueryStream(ctx, prompt, options)
+		for message := range messages {
+			select {
+			case messageChan <- message:
+			case <-ctx.Done():
+				// The caller may have stopped reading; keep draining so the slot is released
+			}
+		}
+		for err := range errs {
+			errorChan <- err
+		}
+	}()
+
+	return messageChan, errorChan
+}
+
+// Stats returns a snapshot of the pool's load and queue times
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.MaxConcurrent = p.max
+	stats.Running = p.running
+	stats.QueuedByKey = make(map[string]int)
+	for _, level := range p.levels {
+		for key, queue := range level.queues {
+			for _, waiter := range queue {
+				if !waiter.canceled {
+					stats.Queued++
+					stats.QueuedByKey[key]++
+				}
+			}
+		}
+	}
+	return stats
+}
+
+// acquire waits for a slot and returns the function releasing it
+func (p *Pool) acquire(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	key, _ := ctx.Value(poolKeyContextKey{}).(string)
+	priority, _ := ctx.Value(priorityContextKey{}).(Priority)
+
+	p.mu.Lock()
+	waiter := &poolWaiter{key: key, queued: time.Now(), ready: make(chan struct{})}
+	level := p.levels[priority]
+	if level == nil {
+		level = &fairQueue{queues: make(map[string][]*poolWaiter)}
+		p.levels[priority] = level
+	}
+	level.push(waiter)
+	p.dispatch()
+	p.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return p.releaseFunc(), nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if waiter.granted {
+			// The slot arrived as the context ended; hand it on
+			p.running--
+			p.dispatch()
+		} else {
+			waiter.canceled = true
+			p.stats.Abandoned++
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (p *Pool) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.running--
+			p.dispatch()
+		})
+	}
+}
+
+// dispatch grants free slots to the next waiters. The caller holds p.mu.
+func (p *Pool) dispatch() {
+	for p.running < p.max {
+		waiter := p.next()
+		if waiter == nil {
+			return
+		}
+		p.running++
+		waiter.granted = true
+		close(waiter.ready)
+
+		wait := time.Since(waiter.queued)
+		p.stats.Started++
+		p.stats.TotalQueueTime += wait
+		if wait > p.stats.MaxQueueTime {
+			p.stats.MaxQueueTime = wait
+		}
+	}
+}
+
+// next pops the waiter to run next: the highest priority level, then the next
+// key in rotation. The caller holds p.mu.
+func (p *Pool) next() *poolWaiter {
+	for {
+		var best *fairQueue
+		var bestPriority Priority
+		for priority, level := range p.levels {
+			if len(level.keys) > 0 && (best == nil || priority > bestPriority) {
+				best, bestPriority = level, priority
+			}
+		}
+		if best == nil {
+			return nil
+		}
+		if waiter := best.pop(); waiter != nil && !waiter.canceled {
+			return waiter
+		}
+	}
+}
+
+func (q *fairQueue) push(waiter *poolWaiter) {
+	if len(q.queues[waiter.key]) == 0 {
+		q.keys = append(q.keys, waiter.key)
+	}
+	q.queues[waiter.key] = append(q.queues[waiter.key], waiter)
+}
+
+// pop removes the first waiter of the key at the head of the rotation and moves
+// the key to the back if it has more waiters
+func (q *fairQueue) pop() *poolWaiter {
+	if len(q.keys) == 0 {
+		return nil
+	}
+	key := q.keys[0]
+	q.keys = q.keys[1:]
+	queue := q.queues[key]
+	waiter := queue[0]
+	if len(queue) > 1 {
+		q.queues[key] = queue[1:]
+		q.keys = append(q.keys, key)
+	} else {
+		delete(q.queues, key)
+	}
+	return waiter
+}
@@ -0,0 +1,173 @@
+package claudecode
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForQueued waits until the pool has n queued queries
+func waitForQueued(t *testing.T, pool *Pool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.Stats().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d queued queries, got %+v", n, pool.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolOrdering(t *testing.T) {
+	pool := NewPool(1)
+	release, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	enqueue := func(name, key string, priority Priority) {
+		ctx := WithPriority(WithPoolKey(context.Background(), key), priority)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := pool.acquire(ctx)
+			if err != nil {
+				t.Errorf("acquire failed: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			release()
+		}()
+	}
+
+	queued := 0
+	for _, waiter := range []struct {
+		name, key string
+		priority  Priority
+	}{
+		{"a1", "a", PriorityNormal},
+		{"a2", "a", PriorityNormal},
+		{"a3", "a", PriorityNormal},
+		{"b1", "b", PriorityNormal},
+		{"low", "a", PriorityLow},
+		{"high", "b", PriorityHigh},
+		{"c1", "c", PriorityNormal},
+	} {
+		enqueue(waiter.name, waiter.key, waiter.priority)
+		queued++
+		waitForQueued(t, pool, queued)
+	}
+	if stats := pool.Stats(); stats.QueuedByKey["a"] != 4 || stats.Running != 1 {
+		t.Errorf("Unexpected stats while queued: %+v", stats)
+	}
+
+	release()
+	release() // releasing twice is harmless
+	wg.Wait()
+
+	expected := "high a1 b1 c1 a2 a3 low"
+	if strings.Join(order, " ") != expected {
+		t.Errorf("Expected order %q, got %q", expected, strings.Join(order, " "))
+	}
+	stats := pool.Stats()
+	if stats.Running != 0 || stats.Queued != 0 || stats.Started != 8 {
+		t.Errorf("Unexpected final stats: %+v", stats)
+	}
+	if stats.MaxQueueTime <= 0 || stats.AverageQueueTime() <= 0 || stats.AverageQueueTime() > stats.MaxQueueTime {
+		t.Errorf("Expected queue times to be recorded, got %+v", stats)
+	}
+}
+
+func TestPoolCancelWhileQueued(t *testing.T) {
+	pool := NewPool(1)
+	release, _ := pool.acquire(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := pool.Query(ctx, "never runs", nil)
+		done <- err
+	}()
+	waitForQueued(t, pool, 1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	stats := pool.Stats()
+	if stats.Queued != 0 || stats.Abandoned != 1 {
+		t.Errorf("Expected the abandoned query to leave the queue, got %+v", stats)
+	}
+	release()
+	if release, err := pool.acquire(context.Background()); err != nil {
+		t.Errorf("Expected the slot to be free, got %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestPoolQuery(t *testing.T) {
+	cli := writeFakeCLI(t, batchFakeCLI)
+	options := &Options{Executable: &cli}
+	pool := NewPool(2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			messages, err := pool.Query(context.Background(), "fast", options)
+			if err != nil || lastResultMessage(messages) == nil {
+				t.Errorf("Expected a result, got %v %v", messages, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	messages, errs := pool.QueryStream(context.Background(), "fast", options)
+	count := 0
+	for range messages {
+		count++
+	}
+	for err := range errs {
+		t.Errorf("Unexpected stream error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 streamed messages, got %d", count)
+	}
+	if stats := pool.Stats(); stats.Started != 5 || stats.Running != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestPoolQueryStreamReleasesOnCancel(t *testing.T) {
+	cli := writeFakeCLI(t, `#!/bin/sh
+cat >/dev/null
+i=0
+while [ $i -lt 50 ]; do
+  echo '{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"chunk"}]}}'
+  i=$((i+1))
+done
+exec sleep 5
+`)
+	pool := NewPool(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	messages, _ := pool.QueryStream(ctx, "abandoned", &Options{Executable: &cli})
+	<-messages
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.Stats().Running != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the slot to be released after cancel, got %+v", pool.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
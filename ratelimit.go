@@ -0,0 +1,259 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = time.Minute
+)
+
+// RateLimiter throttles the start of queries. Query and QueryStream call Wait
+// before launching the CLI and Done once the query has finished, with the usage
+// of its final result (nil when unknown) and its error. A rate limit reported by
+// the CLI is passed to Done as a *RateLimitError, even when it only appears in
+// an error result. Implementations must be safe for concurrent use, so that one
+// limiter can be shared by every query and Pool in a process.
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+	Done(usage *Usage, err error)
+}
+
+// RateLimits configures a TokenBucketLimiter. Zero values disable a limit.
+type RateLimits struct {
+	// RequestsPerMinute bounds how many queries start per minute
+	RequestsPerMinute int
+	// TokensPerMinute bounds the input and output tokens used per minute. Usage is
+	// only known once a query finishes, so queries start while the budget is not
+	// exhausted and large results are paid off before the next one.
+	TokensPerMinute int
+	// MinBackoff is the first pause after a rate-limit error without a retry time (default 1s)
+	MinBackoff time.Duration
+	// MaxBackoff bounds the pause after repeated rate-limit errors (default 1m)
+	MaxBackoff time.Duration
+}
+
+// TokenBucketLimiter is a RateLimiter with token buckets for requests and
+// tokens per minute. Both buckets hold up to a minute's worth and refill
+// continuously. After a rate-limit error no query starts until the retry time
+// reported by the CLI, or an exponential backoff when it reported none.
+type TokenBucketLimiter struct {
+	limits RateLimits
+	now    func() time.Time
+
+	mu           sync.Mutex
+	updated      time.Time
+	requests     float64
+	tokens       float64
+	backoffUntil time.Time
+	failures     int
+}
+
+// NewTokenBucketLimiter creates a limiter starting with full buckets
+func NewTokenBucketLimiter(limits RateLimits) *TokenBucketLimiter {
+	if limits.MinBackoff <= 0 {
+		limits.MinBackoff = defaultMinBackoff
+	}
+	if limits.MaxBackoff < limits.MinBackoff {
+		limits.MaxBackoff = defaultMaxBackoff
+		if limits.MaxBackoff < limits.MinBackoff {
+			limits.MaxBackoff = limits.MinBackoff
+		}
+	}
+	l := &TokenBucketLimiter{limits: limits, now: time.Now}
+	l.updated = l.now()
+	l.requests = float64(limits.RequestsPerMinute)
+	l.tokens = float64(limits.TokensPerMinute)
+	return l
+}
+
+// Wait blocks until a query may start or ctx ends
+func (l *TokenBucketLimiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.reserve()
+		if delay == 0 {
+			return nil
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Done charges the tokens of a finished query and starts or resets the backoff
+func (l *TokenBucketLimiter) Done(usage *Usage, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+
+	if usage != nil && l.limits.TokensPerMinute > 0 {
+		l.tokens -= float64(usage.InputTokens + usage.OutputTokens)
+	}
+
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		if err == nil {
+			l.failures = 0
+		}
+		return
+	}
+	delay := rateLimitErr.RetryAfter
+	if delay <= 0 {
+		delay = l.limits.MinBackoff << l.failures
+		if delay <= 0 || delay > l.limits.MaxBackoff {
+			delay = l.limits.MaxBackoff
+		}
+	}
+	l.failures++
+	if until := l.now().Add(delay); until.After(l.backoffUntil) {
+		l.backoffUntil = until
+	}
+}
+
+// reserve takes a request token and returns 0, or returns how long to wait
+func (l *TokenBucketLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+
+	now := l.now()
+	if now.Before(l.backoffUntil) {
+		return l.backoffUntil.Sub(now)
+	}
+	var delay time.Duration
+	if l.limits.RequestsPerMinute > 0 && l.requests < 1 {
+		delay = refillTime(1-l.requests, l.limits.RequestsPerMinute)
+	}
+	if l.limits.TokensPerMinute > 0 && l.tokens < 0 {
+		if wait := refillTime(-l.tokens, l.limits.TokensPerMinute); wait > delay {
+			delay = wait
+		}
+	}
+	if delay > 0 {
+		return delay
+	}
+	l.requests--
+	return 0
+}
+
+// refill adds the tokens earned since the last update. The caller holds l.mu.
+func (l *TokenBucketLimiter) refill() {
+	now := l.now()
+	elapsed := now.Sub(l.updated).Minutes()
+	l.updated = now
+	if elapsed <= 0 {
+		return
+	}
+	if limit := float64(l.limits.RequestsPerMinute); limit > 0 {
+		l.requests = minFloat(limit, l.requests+elapsed*limit)
+	}
+	if limit := float64(l.limits.TokensPerMinute); limit > 0 {
+		l.tokens = minFloat(limit, l.tokens+elapsed*limit)
+	}
+}
+
+// refillTime is how long a bucket refilling perMinute takes to gain amount
+func refillTime(amount float64, perMinute int) time.Duration {
+	delay := time.Duration(amount / float64(perMinute) * float64(time.Minute))
+	if delay < time.Millisecond {
+		delay = time.Millisecond
+	}
+	return delay
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+var (
+	// rateLimitPattern matches the CLI's "API Error: 429 {...}" messages and the
+	// API's rate_limit_error type, not any output that mentions rate limits
+	rateLimitPattern  = regexp.MustCompile(`API Error: 429\b|\brate_limit_error\b|usage limit reached`)
+	retryAfterPattern = regexp.MustCompile(`(?i)retry[ -]after[: ]+(\d+)`)
+	// usageLimitPattern matches the CLI's "Claude AI usage limit reached|<unix time>" result
+	usageLimitPattern = regexp.MustCompile(`usage limit reached\|(\d+)`)
+)
+
+// classifyRateLimit returns a *RateLimitError when the query failed, or ended
+// in an error result, because of a rate limit. The CLI reports a rate limit in
+// an error result before exiting non-zero, so the result is checked first and
+// the stderr of a *ProcessError only when no error result matches.
+func classifyRateLimit(messages []Message, err error) *RateLimitError {
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return rateLimitErr
+	}
+	if result := lastResultMessage(messages); result != nil && result.IsError && result.Result != nil {
+		if rateLimitPattern.MatchString(*result.Result) {
+			return newRateLimitError(*result.Result, err)
+		}
+	}
+	var processErr *ProcessError
+	if errors.As(err, &processErr) && rateLimitPattern.MatchString(processErr.Stderr) {
+		return newRateLimitError(processErr.Stderr, err)
+	}
+	return nil
+}
+
+func newRateLimitError(message string, cause error) *RateLimitError {
+	message = strings.TrimSpace(message)
+	e := &RateLimitError{Message: truncateText(message, maxInputSummaryLength), Cause: cause}
+	if match := usageLimitPattern.FindStringSubmatch(message); match != nil {
+		if unix, err := strconv.ParseInt(match[1], 10, 64); err == nil {
+			e.RetryAfter = time.Until(time.Unix(unix, 0))
+		}
+	} else if match := retryAfterPattern.FindStringSubmatch(message); match != nil {
+		if seconds, err := strconv.Atoi(match[1]); err == nil {
+			e.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+	if e.RetryAfter < 0 {
+		e.RetryAfter = 0
+	}
+	return e
+}
+
+// waitForRateLimiter waits for Options.RateLimiter, when set, and returns the
+// function to call with the outcome of the query. That function reports it to
+// the limiter and returns the query's error, wrapped in a *RateLimitError when
+// the CLI failed because of a rate limit.
+func waitForRateLimiter(ctx context.Context, options *Options) (func(messages []Message, err error) error, error) {
+	limiter := options.RateLimiter
+	if limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return func(messages []Message, err error) error {
+		rateLimitErr := classifyRateLimit(messages, err)
+		if rateLimitErr != nil && err != nil {
+			err = rateLimitErr
+		}
+		if limiter != nil {
+			var usage *Usage
+			if result := lastResultMessage(messages); result != nil {
+				usage = result.Usage
+			}
+			if rateLimitErr != nil {
+				limiter.Done(usage, rateLimitErr)
+			} else {
+				limiter.Done(usage, err)
+			}
+		}
+		return err
+	}, nil
+}
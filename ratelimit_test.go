@@ -0,0 +1,191 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable time source for limiter tests
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestLimiter(limits RateLimits) (*TokenBucketLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	limiter := NewTokenBucketLimiter(limits)
+	limiter.now = clock.Now
+	limiter.updated = clock.now
+	return limiter, clock
+}
+
+func TestTokenBucketLimiterRequests(t *testing.T) {
+	limiter, clock := newTestLimiter(RateLimits{RequestsPerMinute: 2})
+	if limiter.reserve() != 0 || limiter.reserve() != 0 {
+		t.Fatal("Expected a full bucket to allow a burst of 2")
+	}
+	if delay := limiter.reserve(); delay != 30*time.Second {
+		t.Errorf("Expected to wait 30s for the next request, got %v", delay)
+	}
+	clock.Advance(30 * time.Second)
+	if delay := limiter.reserve(); delay != 0 {
+		t.Errorf("Expected a request after refilling, got %v", delay)
+	}
+}
+
+func TestTokenBucketLimiterTokens(t *testing.T) {
+	limiter, clock := newTestLimiter(RateLimits{TokensPerMinute: 1000})
+	if limiter.reserve() != 0 {
+		t.Fatal("Expected the first query to start")
+	}
+	limiter.Done(&Usage{InputTokens: 1200, OutputTokens: 300}, nil)
+	if delay := limiter.reserve(); delay != 30*time.Second {
+		t.Errorf("Expected to wait until the token debt is paid off, got %v", delay)
+	}
+	clock.Advance(30 * time.Second)
+	if delay := limiter.reserve(); delay != 0 {
+		t.Errorf("Expected a query once tokens refilled, got %v", delay)
+	}
+}
+
+func TestTokenBucketLimiterBackoff(t *testing.T) {
+	limiter, clock := newTestLimiter(RateLimits{MinBackoff: time.Second, MaxBackoff: 3 * time.Second})
+
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		limiter.Done(nil, &RateLimitError{Message: "429"})
+		if delay := limiter.reserve(); delay != expected {
+			t.Errorf("Backoff %d: expected %v, got %v", i, expected, delay)
+		}
+		clock.Advance(expected)
+	}
+
+	limiter.Done(nil, nil)
+	limiter.Done(nil, &RateLimitError{Message: "429", RetryAfter: 10 * time.Second})
+	if delay := limiter.reserve(); delay != 10*time.Second {
+		t.Errorf("Expected the reported retry time, got %v", delay)
+	}
+	clock.Advance(10 * time.Second)
+	limiter.Done(nil, nil)
+	limiter.Done(nil, &RateLimitError{Message: "429"})
+	if delay := limiter.reserve(); delay != time.Second {
+		t.Errorf("Expected success to reset the backoff, got %v", delay)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx); err != context.Canceled {
+		t.Errorf("Expected Wait to stop with the context, got %v", err)
+	}
+}
+
+func TestClassifyRateLimit(t *testing.T) {
+	processErr := &ProcessError{ExitCode: 1, Stderr: "API Error: 429 rate_limit_error. Retry after 12 seconds"}
+	rateLimitErr := classifyRateLimit(nil, processErr)
+	if rateLimitErr == nil || rateLimitErr.RetryAfter != 12*time.Second || rateLimitErr.Cause != processErr {
+		t.Fatalf("Expected a rate-limit error with retry time, got %+v", rateLimitErr)
+	}
+	if classifyRateLimit(nil, &ProcessError{ExitCode: 1, Stderr: "invalid API key"}) != nil {
+		t.Error("Expected other process errors not to be rate limits")
+	}
+	for _, stderr := range []string{"fetched 429 records", "Error: configure the rate limit in settings", "HTTP 429 from example.com"} {
+		if classifyRateLimit(nil, &ProcessError{ExitCode: 1, Stderr: stderr}) != nil {
+			t.Errorf("Expected %q not to be a rate limit", stderr)
+		}
+	}
+	result := &ResultMessage{IsError: true, Result: stringPtr("The tool hit a rate limit (429) on the GitHub API")}
+	if classifyRateLimit([]Message{result}, nil) != nil {
+		t.Error("Expected a result mentioning a rate limit not to be one")
+	}
+	processErr = &ProcessError{ExitCode: 1, Stderr: `API Error: 429 {"type":"error","error":{"type":"rate_limit_error","message":"Number of request tokens has exceeded your per-minute rate limit"}}`}
+	if classifyRateLimit(nil, processErr) == nil {
+		t.Error("Expected the CLI's API error to be a rate limit")
+	}
+
+	reset := time.Now().Add(time.Hour).Unix()
+	result = &ResultMessage{IsError: true, Result: stringPtr("Claude AI usage limit reached|" + strconv.FormatInt(reset, 10))}
+	rateLimitErr = classifyRateLimit([]Message{result}, nil)
+	if rateLimitErr == nil || rateLimitErr.RetryAfter < 59*time.Minute {
+		t.Errorf("Expected the usage limit reset time, got %+v", rateLimitErr)
+	}
+}
+
+// recordingLimiter records calls to Done
+type recordingLimiter struct {
+	waits int
+	done  []error
+	usage []*Usage
+}
+
+func (l *recordingLimiter) Wait(ctx context.Context) error {
+	l.waits++
+	return nil
+}
+
+func (l *recordingLimiter) Done(usage *Usage, err error) {
+	l.usage = append(l.usage, usage)
+	l.done = append(l.done, err)
+}
+
+func TestQueryRateLimiter(t *testing.T) {
+	limiter := &recordingLimiter{}
+	cli := writeFakeCLI(t, batchFakeCLI)
+	options := &Options{Executable: &cli, RateLimiter: limiter}
+	if _, err := Query(context.Background(), "fast", options); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	messages, errs := QueryStream(context.Background(), "fast", options)
+	for range messages {
+	}
+	for err := range errs {
+		t.Errorf("Unexpected stream error: %v", err)
+	}
+	if limiter.waits != 2 || len(limiter.done) != 2 || limiter.usage[1] == nil || limiter.usage[1].OutputTokens != 2 {
+		t.Errorf("Expected both queries to report usage, got %+v", limiter)
+	}
+
+	limited := writeFakeCLI(t, `echo "API Error: 429 Too Many Requests" >&2; exit 1`)
+	options.Executable = &limited
+	_, err := Query(context.Background(), "hi", options)
+	var rateLimitErr *RateLimitError
+	var processErr *ProcessError
+	if !errors.As(err, &rateLimitErr) || !errors.As(err, &processErr) {
+		t.Errorf("Expected a rate-limit error wrapping the process error, got %v", err)
+	}
+	if !errors.As(limiter.done[2], &rateLimitErr) {
+		t.Errorf("Expected the limiter to see the rate-limit error, got %v", limiter.done[2])
+	}
+
+	// The CLI usually reports the rate limit in an error result, then exits non-zero
+	limitedResult := writeFakeCLI(t, `
+cat >/dev/null
+echo '{"type":"result","subtype":"success","is_error":true,"session_id":"s1","result":"API Error: 429 {\"type\":\"error\",\"error\":{\"type\":\"rate_limit_error\"}} Retry after 7 seconds"}'
+exit 1
+`)
+	options.Executable = &limitedResult
+	for _, run := range []func() error{
+		func() error {
+			_, err := Query(context.Background(), "hi", options)
+			return err
+		},
+		func() error {
+			messages, errs := QueryStream(context.Background(), "hi", options)
+			for range messages {
+			}
+			return <-errs
+		},
+	} {
+		err := run()
+		if !errors.As(err, &rateLimitErr) || !errors.As(err, &processErr) || rateLimitErr.RetryAfter != 7*time.Second {
+			t.Errorf("Expected a rate-limit error from the error result, got %v", err)
+		}
+		if last := limiter.done[len(limiter.done)-1]; !errors.As(last, &rateLimitErr) {
+			t.Errorf("Expected the limiter to see the rate-limit error, got %v", last)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package render
+
+import (
+	"html/template"
+	"io"
+
+	claudecode "github.com/kannae97/claude-code-sdk-go"
+)
+
+// HTML writes the messages as a self-contained HTML page with inline styles and
+// no external resources. Tool calls are collapsible and subagent messages are
+// nested inside the call that started them.
+func HTML(w io.Writer, messages []claudecode.Message, options *Options) error {
+	return htmlTemplate.Execute(w, buildTranscript(messages, options))
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 960px; margin: 2rem auto; padding: 0 1rem; color: #1f2328; line-height: 1.5; }
+table { border-collapse: collapse; margin-bottom: 1.5rem; }
+th, td { border: 1px solid #d0d7de; padding: .3rem .8rem; text-align: left; }
+th { background: #f6f8fa; }
+.entry { border-left: 4px solid #d0d7de; padding: .2rem 1rem; margin: 1rem 0; }
+.entry.user { border-color: #0969da; }
+.entry.assistant { border-color: #8250df; }
+.role { font-weight: 600; font-size: .85rem; text-transform: uppercase; color: #57606a; }
+.text { white-space: pre-wrap; }
+.thinking { white-space: pre-wrap; color: #57606a; font-style: italic; }
+details { border: 1px solid #d0d7de; border-radius: 6px; margin: .5rem 0; padding: .3rem .8rem; background: #f6f8fa; }
+details.error { border-color: #cf222e; }
+summary { cursor: pointer; }
+summary code { font-weight: 600; }
+.status { color: #57606a; font-size: .85rem; }
+.error .status { color: #cf222e; }
+pre { background: #fff; border: 1px solid #d0d7de; border-radius: 6px; padding: .6rem; overflow-x: auto; font-size: .85rem; }
+.subagent { margin-left: .5rem; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{with .Summary}}<table>
+<tr><th>Session</th><th>Model</th><th>Status</th><th>Turns</th><th>Duration</th><th>Cost</th></tr>
+<tr><td>{{.SessionID}}</td><td>{{.Model}}</td><td>{{.Status}}</td><td>{{.Turns}}</td><td>{{.Duration}}</td><td>{{.Cost}}</td></tr>
+</table>
+{{end}}{{template "entries" .Entries}}
+</body>
+</html>
+{{define "entries"}}{{range .}}<div class="entry {{if eq .Role "User"}}user{{else}}assistant{{end}}">
+<div class="role">{{.Role}}</div>
+{{range .Blocks}}{{if .Tool}}{{template "tool" .Tool}}{{else if .Thinking}}<details><summary>Thinking</summary><div class="thinking">{{.Thinking}}</div></details>
+{{else}}<div class="text">{{.Text}}</div>
+{{end}}{{end}}</div>
+{{end}}{{end}}
+{{define "tool"}}<details{{if .IsError}} class="error"{{end}}>
+<summary><code>{{.Name}}</code> {{.Summary}} <span class="status">{{if .IsError}}error{{else if not .HasResult}}pending{{end}}</span></summary>
+<pre>{{.Input}}</pre>
+{{if .HasResult}}<div class="role">{{if .IsError}}Error{{else}}Result{{end}}</div>
+<pre>{{.Result}}</pre>
+{{end}}{{if .Subagent}}<div class="role">Subagent</div>
+<div class="subagent">{{template "entries" .Subagent}}</div>
+{{end}}</details>
+{{end}}`))
@@ -0,0 +1,106 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	claudecode "github.com/kannae97/claude-code-sdk-go"
+)
+
+// Markdown writes the messages as GitHub-flavored Markdown. Tool calls are
+// collapsible <details> sections holding the input, the result and the
+// messages of any subagent the call started.
+func Markdown(w io.Writer, messages []claudecode.Message, options *Options) error {
+	t := buildTranscript(messages, options)
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "# %s\n\n", t.Title)
+	if s := t.Summary; s != nil {
+		out.WriteString("| Session | Model | Status | Turns | Duration | Cost |\n")
+		out.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+		fmt.Fprintf(out, "| %s | %s | %s | %d | %s | %s |\n\n",
+			cell(s.SessionID), cell(s.Model), cell(s.Status), s.Turns, cell(s.Duration), cell(s.Cost))
+	}
+
+	writeMarkdownEntries(out, t.Entries, 2)
+	return out.Flush()
+}
+
+func writeMarkdownEntries(out *bufio.Writer, entries []entry, level int) {
+	heading := strings.Repeat("#", level)
+	for _, e := range entries {
+		fmt.Fprintf(out, "%s %s\n\n", heading, e.Role)
+		for _, b := range e.Blocks {
+			switch {
+			case b.Tool != nil:
+				writeMarkdownToolCall(out, b.Tool, level)
+			case b.Thinking != "":
+				out.WriteString("<details>\n<summary>Thinking</summary>\n\n")
+				writeQuoted(out, b.Thinking)
+				out.WriteString("\n</details>\n\n")
+			default:
+				out.WriteString(strings.TrimSpace(b.Text))
+				out.WriteString("\n\n")
+			}
+		}
+	}
+}
+
+func writeMarkdownToolCall(out *bufio.Writer, call *toolCall, level int) {
+	status := ""
+	switch {
+	case call.IsError:
+		status = " ❌"
+	case !call.HasResult:
+		status = " ⏳"
+	}
+	fmt.Fprintf(out, "<details>\n<summary><b>%s</b> %s%s</summary>\n\n",
+		html.EscapeString(call.Name), html.EscapeString(call.Summary), status)
+
+	writeFenced(out, "json", call.Input)
+	if call.HasResult {
+		if call.IsError {
+			out.WriteString("**Error**\n\n")
+		} else {
+			out.WriteString("**Result**\n\n")
+		}
+		writeFenced(out, "", call.Result)
+	}
+	if len(call.Subagent) > 0 {
+		out.WriteString("**Subagent**\n\n")
+		nested := level + 1
+		if nested > 6 {
+			nested = 6
+		}
+		writeMarkdownEntries(out, call.Subagent, nested)
+	}
+	out.WriteString("</details>\n\n")
+}
+
+// writeFenced writes content in a code fence longer than any backtick run it contains
+func writeFenced(out *bufio.Writer, language, content string) {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(out, "%s%s\n%s\n%s\n\n", fence, language, strings.TrimRight(content, "\n"), fence)
+}
+
+func writeQuoted(out *bufio.Writer, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		out.WriteString("> ")
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+}
+
+// cell escapes a value for a Markdown table cell
+func cell(value string) string {
+	if value == "" {
+		return "–"
+	}
+	return strings.ReplaceAll(value, "|", `\|`)
+}
@@ -0,0 +1,401 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	claudecode "github.com/kannae97/claude-code-sdk-go"
+)
+
+// defaultMaxResultLines bounds the tool result excerpt printed by StreamPrinter
+const defaultMaxResultLines = 8
+
+// resultLineWidth and maxResultChars bound the excerpt in runes, for results
+// with very long lines such as minified JSON or base64 data
+const (
+	resultLineWidth = 160
+	maxResultChars  = 1000
+)
+
+// spinnerInterval is how often the in-flight tool spinner redraws
+const spinnerInterval = 100 * time.Millisecond
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// ANSI escape sequences used by StreamPrinter
+const (
+	ansiReset     = "\033[0m"
+	ansiBold      = "\033[1m"
+	ansiDim       = "\033[2m"
+	ansiItalic    = "\033[3m"
+	ansiRed       = "\033[31m"
+	ansiGreen     = "\033[32m"
+	ansiYellow    = "\033[33m"
+	ansiCyan      = "\033[36m"
+	ansiClearLine = "\r\033[K"
+)
+
+// PrinterOptions configures a StreamPrinter
+type PrinterOptions struct {
+	// Color forces colors and spinners on or off. By default they are enabled
+	// when the writer is a terminal and NO_COLOR is unset.
+	Color *bool
+	// MaxResultLines truncates tool results to this many lines. Zero means the
+	// default of 8; a negative value hides tool results. Long lines are cut as
+	// well, so a single huge line does not flood the terminal.
+	MaxResultLines int
+	// ShowThinking prints thinking blocks
+	ShowThinking bool
+}
+
+// StreamPrinter renders QueryStream output live to a terminal: assistant text,
+// tool calls with a spinner while they run, truncated tool results, nested
+// subagent output and a cost and usage footer. On writers that are not a
+// terminal it prints the same information as plain text.
+type StreamPrinter struct {
+	w              io.Writer
+	color          bool
+	maxResultLines int
+	showThinking   bool
+
+	mu       sync.Mutex
+	inFlight map[string]*inFlightTool
+	depths   map[string]int
+	frame    int
+	spinning bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type inFlightTool struct {
+	name    string
+	started time.Time
+	depth   int
+}
+
+// NewStreamPrinter creates a printer writing to w
+func NewStreamPrinter(w io.Writer, options *PrinterOptions) *StreamPrinter {
+	if options == nil {
+		options = &PrinterOptions{}
+	}
+	color := isTerminal(w) && os.Getenv("NO_COLOR") == ""
+	if options.Color != nil {
+		color = *options.Color
+	}
+	maxResultLines := options.MaxResultLines
+	if maxResultLines == 0 {
+		maxResultLines = defaultMaxResultLines
+	}
+	return &StreamPrinter{
+		w:              w,
+		color:          color,
+		maxResultLines: maxResultLines,
+		showThinking:   options.ShowThinking,
+		inFlight:       make(map[string]*inFlightTool),
+		depths:         make(map[string]int),
+	}
+}
+
+// isTerminal reports whether w is a character device such as a TTY
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Run prints messages until the channel closes and returns the first error from
+// errs, so it can consume QueryStream output directly:
+//
+//	messages, errs := claudecode.QueryStream(ctx, prompt, options)
+//	err := render.NewStreamPrinter(os.Stdout, nil).Run(ctx, messages, errs)
+func (p *StreamPrinter) Run(ctx context.Context, messages <-chan claudecode.Message, errs <-chan error) error {
+	defer p.Close()
+
+	var firstErr error
+	for messages != nil || errs != nil {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				messages = nil
+				continue
+			}
+			p.Print(message)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+				p.printError(err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return firstErr
+}
+
+// Print renders a single message
+func (p *StreamPrinter) Print(message claudecode.Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clearSpinner()
+
+	switch m := message.(type) {
+	case *claudecode.SystemMessage:
+		if m.Subtype == "init" {
+			line := "session " + m.SessionID
+			if m.Model != nil {
+				line += " · " + *m.Model
+			}
+			p.println(0, p.style(ansiDim, line))
+		}
+	case *claudecode.AssistantMessage:
+		depth := p.depthOf(m.ParentToolUseID)
+		for _, block := range m.ContentBlocks {
+			p.printAssistantBlock(block, depth)
+		}
+	case *claudecode.UserMessage:
+		depth := p.depthOf(m.ParentToolUseID)
+		for _, block := range m.ContentBlocks {
+			switch b := block.(type) {
+			case *claudecode.ToolResultBlock:
+				p.printToolResult(b)
+			case *claudecode.TextBlock:
+				if m.ParentToolUseID != nil {
+					// The prompt a subagent was started with
+					p.printText(depth, p.style(ansiDim, "> "+firstLine(b.Text)))
+				}
+			}
+		}
+	case *claudecode.ResultMessage:
+		p.printFooter(m)
+	}
+
+	p.updateSpinner()
+}
+
+// Close stops the spinner. Run calls it automatically.
+func (p *StreamPrinter) Close() {
+	p.mu.Lock()
+	p.clearSpinner()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (p *StreamPrinter) printAssistantBlock(block claudecode.ContentBlock, depth int) {
+	switch b := block.(type) {
+	case *claudecode.TextBlock:
+		if text := strings.TrimSpace(b.Text); text != "" {
+			p.printText(depth, text)
+		}
+	case *claudecode.ThinkingBlock:
+		if p.showThinking && strings.TrimSpace(b.Thinking) != "" {
+			p.printText(depth, p.style(ansiDim+ansiItalic, strings.TrimSpace(b.Thinking)))
+		}
+	case *claudecode.ToolUseBlock:
+		p.inFlight[b.ID] = &inFlightTool{name: b.Name, started: time.Now(), depth: depth}
+		p.depths[b.ID] = depth + 1
+		line := p.style(ansiCyan, "● ") + p.style(ansiBold, b.Name)
+		if summary := b.Summary(); summary != "" {
+			line += " " + summary
+		}
+		p.println(depth, line)
+	}
+}
+
+func (p *StreamPrinter) printToolResult(result *claudecode.ToolResultBlock) {
+	tool := p.inFlight[result.ToolUseID]
+	delete(p.inFlight, result.ToolUseID)
+	depth, name, elapsed := 0, "tool", ""
+	if tool != nil {
+		depth, name = tool.depth, tool.name
+		elapsed = " " + formatDuration(time.Since(tool.started))
+	}
+
+	if result.IsError {
+		p.println(depth, p.style(ansiRed, fmt.Sprintf("  ✗ %s failed%s", name, elapsed)))
+	} else {
+		p.println(depth, p.style(ansiGreen, "  ✓ ")+p.style(ansiDim, name+elapsed))
+	}
+
+	if p.maxResultLines < 0 {
+		return
+	}
+	lines := strings.Split(strings.TrimRight(result.Text(), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return
+	}
+	total := len(lines)
+	if total > p.maxResultLines {
+		lines = lines[:p.maxResultLines]
+	}
+	budget, shown := maxResultChars, 0
+	for _, line := range lines {
+		if budget <= 0 {
+			break
+		}
+		runes := []rune(line)
+		limit := min(resultLineWidth, budget)
+		if len(runes) > limit {
+			line = string(runes[:limit]) + "…"
+			budget -= limit
+		} else {
+			budget -= len(runes)
+		}
+		p.println(depth, p.style(ansiDim, "    │ "+line))
+		shown++
+	}
+	if hidden := total - shown; hidden > 0 {
+		p.println(depth, p.style(ansiDim, fmt.Sprintf("    │ … %d more lines", hidden)))
+	}
+}
+
+func (p *StreamPrinter) printFooter(result *claudecode.ResultMessage) {
+	parts := []string{result.Subtype}
+	if result.NumTurns > 0 {
+		parts = append(parts, fmt.Sprintf("%d turns", result.NumTurns))
+	}
+	if result.DurationMs > 0 {
+		parts = append(parts, formatDuration(time.Duration(result.DurationMs)*time.Millisecond))
+	}
+	if result.TotalCostUSD != nil {
+		parts = append(parts, fmt.Sprintf("$%.4f", *result.TotalCostUSD))
+	}
+	if result.Usage != nil {
+		parts = append(parts, fmt.Sprintf("%d in / %d out tokens", result.Usage.InputTokens, result.Usage.OutputTokens))
+	}
+	color := ansiDim
+	if result.IsError {
+		color = ansiRed
+	}
+	p.println(0, "")
+	p.println(0, p.style(color, "── "+strings.Join(parts, " · ")))
+}
+
+func (p *StreamPrinter) printError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clearSpinner()
+	p.println(0, p.style(ansiRed, "error: "+err.Error()))
+	p.updateSpinner()
+}
+
+func (p *StreamPrinter) printText(depth int, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		p.println(depth, line)
+	}
+}
+
+func (p *StreamPrinter) println(depth int, line string) {
+	if line == "" {
+		fmt.Fprintln(p.w)
+		return
+	}
+	fmt.Fprintf(p.w, "%s%s\n", strings.Repeat("  ", depth), line)
+}
+
+func (p *StreamPrinter) style(code, text string) string {
+	if !p.color {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+// depthOf returns the indentation of a message sent by the subagent of parentToolUseID
+func (p *StreamPrinter) depthOf(parentToolUseID *string) int {
+	if parentToolUseID == nil {
+		return 0
+	}
+	return p.depths[*parentToolUseID]
+}
+
+// updateSpinner starts or stops the spinner goroutine to match the in-flight tools.
+// The caller holds p.mu.
+func (p *StreamPrinter) updateSpinner() {
+	if !p.color {
+		return
+	}
+	if len(p.inFlight) > 0 && p.stop == nil {
+		p.stop, p.done = make(chan struct{}), make(chan struct{})
+		go p.spin(p.stop, p.done)
+	} else if len(p.inFlight) == 0 && p.stop != nil {
+		// The goroutine exits on its own; it cannot be awaited while p.mu is held
+		close(p.stop)
+		p.stop, p.done = nil, nil
+	}
+	p.drawSpinner()
+}
+
+func (p *StreamPrinter) spin(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.frame++
+			p.drawSpinner()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// drawSpinner redraws the status line of in-flight tools. The caller holds p.mu.
+func (p *StreamPrinter) drawSpinner() {
+	if len(p.inFlight) == 0 {
+		p.clearSpinner()
+		return
+	}
+	tools := make([]*inFlightTool, 0, len(p.inFlight))
+	for _, tool := range p.inFlight {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].started.Before(tools[j].started) })
+
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = fmt.Sprintf("%s %s", tool.name, formatDuration(time.Since(tool.started)))
+	}
+	frame := spinnerFrames[p.frame%len(spinnerFrames)]
+	fmt.Fprintf(p.w, "%s%s %s", ansiClearLine, p.style(ansiYellow, frame), p.style(ansiDim, strings.Join(names, ", ")))
+	p.spinning = true
+}
+
+// clearSpinner erases the status line before regular output. The caller holds p.mu.
+func (p *StreamPrinter) clearSpinner() {
+	if p.spinning {
+		fmt.Fprint(p.w, ansiClearLine)
+		p.spinning = false
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
@@ -0,0 +1,118 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	claudecode "github.com/kannae97/claude-code-sdk-go"
+)
+
+func streamMessages(messages []claudecode.Message, err error) (<-chan claudecode.Message, <-chan error) {
+	messageChan := make(chan claudecode.Message, len(messages))
+	errorChan := make(chan error, 1)
+	for _, message := range messages {
+		messageChan <- message
+	}
+	if err != nil {
+		errorChan <- err
+	}
+	close(messageChan)
+	close(errorChan)
+	return messageChan, errorChan
+}
+
+func TestStreamPrinterPlain(t *testing.T) {
+	var buf bytes.Buffer
+	printer := NewStreamPrinter(&buf, &PrinterOptions{MaxResultLines: 1})
+	messages, errs := streamMessages(testMessages(), nil)
+	if err := printer.Run(context.Background(), messages, errs); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	out := buf.String()
+
+	if strings.Contains(out, "\033[") {
+		t.Errorf("Expected plain output for a non-terminal writer, got %q", out)
+	}
+	expected := []string{
+		"session abc-123 · claude-sonnet-4-5\n",
+		"Running the tests first.\n",
+		"● Bash go test ./...\n",
+		"  ✗ Bash failed",
+		"    │ --- FAIL: TestX\n    │ … 2 more lines\n",
+		"● Task Find TODOs\n",
+		"  ● Grep TODO\n",
+		"    ✓ Grep",
+		"── success · 3 turns · 12.5s · $0.0123",
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(out, fragment) {
+			t.Errorf("Expected output to contain %q, got:\n%s", fragment, out)
+		}
+	}
+	if strings.Contains(out, "Start with the tests") {
+		t.Error("Expected thinking to be hidden by default")
+	}
+}
+
+func TestStreamPrinterColor(t *testing.T) {
+	var buf bytes.Buffer
+	color := true
+	printer := NewStreamPrinter(&buf, &PrinterOptions{Color: &color, MaxResultLines: -1})
+	printer.Print(&claudecode.AssistantMessage{ContentBlocks: []claudecode.ContentBlock{
+		&claudecode.ToolUseBlock{ID: "toolu_1", Name: claudecode.ToolRead, Input: map[string]interface{}{"file_path": "main.go"}},
+	}})
+	printer.Print(&claudecode.UserMessage{ContentBlocks: []claudecode.ContentBlock{
+		&claudecode.ToolResultBlock{ToolUseID: "toolu_1", Content: "package main"},
+	}})
+	printer.Close()
+	out := buf.String()
+
+	if !strings.Contains(out, ansiCyan+"● "+ansiReset) {
+		t.Errorf("Expected colored tool marker, got %q", out)
+	}
+	if !strings.Contains(out, spinnerFrames[0]) || !strings.Contains(out, ansiClearLine) {
+		t.Errorf("Expected spinner to be drawn and cleared, got %q", out)
+	}
+	if strings.Contains(out, "package main") {
+		t.Error("Expected results to be hidden with negative MaxResultLines")
+	}
+}
+
+func TestStreamPrinterLongResultLines(t *testing.T) {
+	var buf bytes.Buffer
+	printer := NewStreamPrinter(&buf, nil)
+	printer.Print(&claudecode.AssistantMessage{ContentBlocks: []claudecode.ContentBlock{
+		&claudecode.ToolUseBlock{ID: "toolu_1", Name: claudecode.ToolRead, Input: map[string]interface{}{"file_path": "data.json"}},
+	}})
+	minified := strings.Repeat("x", 100000)
+	printer.Print(&claudecode.UserMessage{ContentBlocks: []claudecode.ContentBlock{
+		&claudecode.ToolResultBlock{ToolUseID: "toolu_1", Content: strings.Repeat(minified+"\n", 8)},
+	}})
+	printer.Close()
+	out := buf.String()
+
+	if len(out) > 2*maxResultChars {
+		t.Errorf("Expected the result excerpt to be capped, got %d bytes", len(out))
+	}
+	if !strings.Contains(out, "    │ "+strings.Repeat("x", resultLineWidth)+"…\n") {
+		t.Errorf("Expected long lines to be cut at %d characters, got:\n%s", resultLineWidth, out)
+	}
+	if !strings.Contains(out, "more lines") {
+		t.Errorf("Expected lines beyond the character budget to be counted as hidden, got:\n%s", out)
+	}
+}
+
+func TestStreamPrinterError(t *testing.T) {
+	var buf bytes.Buffer
+	messages, errs := streamMessages(nil, errors.New("boom"))
+	err := NewStreamPrinter(&buf, nil).Run(context.Background(), messages, errs)
+	if err == nil || err.Error() != "boom" {
+		t.Errorf("Expected the stream error to be returned, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "error: boom") {
+		t.Errorf("Expected the error to be printed, got %q", buf.String())
+	}
+}
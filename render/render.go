@@ -0,0 +1,200 @@
+// Package render turns Claude Code transcripts into readable Markdown and
+// self-contained HTML documents.
+//
+//	messages, _ := claudecode.LoadSession(sessionID)
+//	render.Markdown(os.Stdout, messages, nil)
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	claudecode "github.com/kannae97/claude-code-sdk-go"
+)
+
+// defaultMaxResultLength bounds rendered tool results unless Options overrides it
+const defaultMaxResultLength = 5000
+
+// Options configures rendering
+type Options struct {
+	// Title is the document heading; defaults to "Claude Code transcript"
+	Title string
+	// MaxResultLength truncates tool results to this many characters. Zero means
+	// the default of 5000; a negative value disables truncation.
+	MaxResultLength int
+	// HideThinking omits thinking blocks
+	HideThinking bool
+}
+
+func (o *Options) title() string {
+	if o == nil || o.Title == "" {
+		return "Claude Code transcript"
+	}
+	return o.Title
+}
+
+func (o *Options) maxResultLength() int {
+	if o == nil || o.MaxResultLength == 0 {
+		return defaultMaxResultLength
+	}
+	return o.MaxResultLength
+}
+
+func (o *Options) hideThinking() bool {
+	return o != nil && o.HideThinking
+}
+
+// transcript is the renderer-neutral view of a message list
+type transcript struct {
+	Title   string
+	Summary *summary
+	Entries []entry
+}
+
+// summary describes the run from the init SystemMessage and the ResultMessage
+type summary struct {
+	SessionID string
+	Model     string
+	Turns     int
+	Duration  string
+	Cost      string
+	Status    string
+	IsError   bool
+}
+
+// entry is a user, assistant or system message with renderable content
+type entry struct {
+	Role   string
+	Blocks []block
+}
+
+// block is either text, thinking or a tool call
+type block struct {
+	Text     string
+	Thinking string
+	Tool     *toolCall
+}
+
+type toolCall struct {
+	Name    string
+	Summary string
+	Input   string
+	// Result is empty while the call has no result
+	Result    string
+	HasResult bool
+	IsError   bool
+	Subagent  []entry
+}
+
+func buildTranscript(messages []claudecode.Message, options *Options) *transcript {
+	t := &transcript{Title: options.title()}
+	tree := claudecode.BuildTranscriptTree(messages)
+
+	var model string
+	for _, message := range messages {
+		switch m := message.(type) {
+		case *claudecode.SystemMessage:
+			if m.Subtype == "init" && m.Model != nil {
+				model = *m.Model
+			}
+		case *claudecode.ResultMessage:
+			t.Summary = newSummary(m)
+		}
+	}
+	if t.Summary == nil && model != "" {
+		t.Summary = &summary{}
+	}
+	if t.Summary != nil {
+		t.Summary.Model = model
+	}
+
+	t.Entries = buildEntries(tree.Roots, options)
+	return t
+}
+
+func newSummary(result *claudecode.ResultMessage) *summary {
+	s := &summary{
+		SessionID: result.SessionID,
+		Turns:     result.NumTurns,
+		Duration:  (time.Duration(result.DurationMs) * time.Millisecond).String(),
+		Status:    result.Subtype,
+		IsError:   result.IsError,
+	}
+	if result.TotalCostUSD != nil {
+		s.Cost = fmt.Sprintf("$%.4f", *result.TotalCostUSD)
+	}
+	return s
+}
+
+func buildEntries(nodes []*claudecode.TranscriptNode, options *Options) []entry {
+	var entries []entry
+	for _, node := range nodes {
+		var role string
+		switch node.Message.(type) {
+		case *claudecode.UserMessage:
+			role = "User"
+		case *claudecode.AssistantMessage:
+			role = "Assistant"
+		default:
+			// System and result messages are summarized in the header
+			continue
+		}
+
+		calls := make(map[*claudecode.ToolUseBlock]*claudecode.ToolCall, len(node.ToolCalls))
+		for _, call := range node.ToolCalls {
+			calls[call.Use] = call
+		}
+
+		e := entry{Role: role}
+		for _, contentBlock := range node.Message.Content() {
+			switch b := contentBlock.(type) {
+			case *claudecode.TextBlock:
+				if strings.TrimSpace(b.Text) != "" {
+					e.Blocks = append(e.Blocks, block{Text: b.Text})
+				}
+			case *claudecode.ThinkingBlock:
+				if !options.hideThinking() && strings.TrimSpace(b.Thinking) != "" {
+					e.Blocks = append(e.Blocks, block{Thinking: b.Thinking})
+				}
+			case *claudecode.ToolUseBlock:
+				e.Blocks = append(e.Blocks, block{Tool: newToolCall(calls[b], b, options)})
+			}
+			// Tool results are rendered with their tool call
+		}
+		if len(e.Blocks) > 0 {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func newToolCall(call *claudecode.ToolCall, use *claudecode.ToolUseBlock, options *Options) *toolCall {
+	input, err := json.MarshalIndent(use.Input, "", "  ")
+	if err != nil {
+		input = []byte(fmt.Sprintf("%v", use.Input))
+	}
+	tc := &toolCall{Name: use.Name, Summary: use.Summary(), Input: string(input)}
+	if call == nil {
+		return tc
+	}
+	if call.Result != nil {
+		tc.HasResult = true
+		tc.IsError = call.Result.IsError
+		tc.Result = truncate(call.Result.Text(), options.maxResultLength())
+	}
+	tc.Subagent = buildEntries(call.Subagent, options)
+	return tc
+}
+
+func truncate(s string, limit int) string {
+	if limit < 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return fmt.Sprintf("%s\n… (%d more characters)", string(runes[:limit]), len(runes)-limit)
+}
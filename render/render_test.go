@@ -0,0 +1,100 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	claudecode "github.com/kannae97/claude-code-sdk-go"
+)
+
+func testMessages() []claudecode.Message {
+	model := "claude-sonnet-4-5"
+	cost := 0.0123
+	task := "toolu_task"
+	result := "Done"
+	return []claudecode.Message{
+		&claudecode.SystemMessage{Subtype: "init", SessionID: "abc-123", Model: &model},
+		&claudecode.UserMessage{ContentBlocks: []claudecode.ContentBlock{&claudecode.TextBlock{Text: "Review <main.go>"}}},
+		&claudecode.AssistantMessage{ContentBlocks: []claudecode.ContentBlock{
+			&claudecode.ThinkingBlock{Thinking: "Start with the tests"},
+			&claudecode.TextBlock{Text: "Running the tests first."},
+			&claudecode.ToolUseBlock{ID: "toolu_bash", Name: claudecode.ToolBash, Input: map[string]interface{}{"command": "go test ./..."}},
+			&claudecode.ToolUseBlock{ID: "toolu_task", Name: claudecode.ToolTask, Input: map[string]interface{}{"description": "Find TODOs", "prompt": "..."}},
+		}},
+		&claudecode.UserMessage{ContentBlocks: []claudecode.ContentBlock{
+			&claudecode.ToolResultBlock{ToolUseID: "toolu_bash", Content: "--- FAIL: TestX\n```\nbackticks", IsError: true},
+		}},
+		&claudecode.AssistantMessage{ParentToolUseID: &task, ContentBlocks: []claudecode.ContentBlock{
+			&claudecode.ToolUseBlock{ID: "toolu_grep", Name: claudecode.ToolGrep, Input: map[string]interface{}{"pattern": "TODO"}},
+		}},
+		&claudecode.UserMessage{ParentToolUseID: &task, ContentBlocks: []claudecode.ContentBlock{
+			&claudecode.ToolResultBlock{ToolUseID: "toolu_grep", Content: strings.Repeat("a", 50)},
+		}},
+		&claudecode.UserMessage{ContentBlocks: []claudecode.ContentBlock{
+			&claudecode.ToolResultBlock{ToolUseID: "toolu_task", Content: []interface{}{map[string]interface{}{"type": "text", "text": "No TODOs"}}},
+		}},
+		&claudecode.ResultMessage{Subtype: "success", SessionID: "abc-123", NumTurns: 3, DurationMs: 12500, TotalCostUSD: &cost, Result: &result},
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Markdown(&buf, testMessages(), &Options{Title: "Review", MaxResultLength: 30}); err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	out := buf.String()
+
+	expected := []string{
+		"# Review\n",
+		"| abc-123 | claude-sonnet-4-5 | success | 3 | 12.5s | $0.0123 |",
+		"## User\n\nReview <main.go>",
+		"<summary>Thinking</summary>\n\n> Start with the tests",
+		"<summary><b>Bash</b> go test ./... ❌</summary>",
+		"```json\n{\n  \"command\": \"go test ./...\"\n}\n```",
+		"**Error**\n\n````\n--- FAIL: TestX\n```\nbackticks\n````",
+		"<summary><b>Task</b> Find TODOs</summary>",
+		"**Subagent**\n\n### Assistant",
+		"… (20 more characters)",
+		"No TODOs",
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(out, fragment) {
+			t.Errorf("Expected Markdown to contain %q, got:\n%s", fragment, out)
+		}
+	}
+	if strings.Count(out, "<details>") != strings.Count(out, "</details>") {
+		t.Error("Expected balanced <details> sections")
+	}
+	if strings.Contains(out, "## Result") || strings.Contains(out, "## System") {
+		t.Error("Expected system and result messages only in the header")
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := HTML(&buf, testMessages(), &Options{HideThinking: true}); err != nil {
+		t.Fatalf("HTML failed: %v", err)
+	}
+	out := buf.String()
+
+	expected := []string{
+		"<title>Claude Code transcript</title>",
+		"<td>$0.0123</td>",
+		"Review &lt;main.go&gt;",
+		`<details class="error">`,
+		"<code>Grep</code> TODO",
+		`<div class="subagent">`,
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(out, fragment) {
+			t.Errorf("Expected HTML to contain %q", fragment)
+		}
+	}
+	if strings.Contains(out, "Start with the tests") {
+		t.Error("Expected thinking to be hidden")
+	}
+	if strings.Contains(out, "<script") || strings.Contains(out, "href=") || strings.Contains(out, "src=") {
+		t.Error("Expected a self-contained page without external resources or scripts")
+	}
+}
@@ -0,0 +1,335 @@
+package claudecode
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxTranscriptLineSize bounds a single transcript line; tool results can be large
+const maxTranscriptLineSize = 32 * 1024 * 1024
+
+// projectDirPattern matches the characters the CLI replaces when naming project directories
+var projectDirPattern = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// sessionIDPattern matches the session IDs the CLI names transcripts after
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// SessionInfo summarizes a session transcript persisted by the CLI
+type SessionInfo struct {
+	ID string `json:"id"`
+	// Path is the transcript file
+	Path string `json:"path"`
+	// Cwd is the working directory the session ran in
+	Cwd string `json:"cwd,omitempty"`
+	// FirstPrompt is the first prompt typed by the user
+	FirstPrompt string `json:"first_prompt,omitempty"`
+	// Summary is the CLI-generated session summary, when available
+	Summary      string    `json:"summary,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	MessageCount int       `json:"message_count"`
+	Usage        Usage     `json:"usage"`
+	CostUSD      float64   `json:"cost_usd,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// SessionStore reads session transcripts from a Claude config directory
+type SessionStore struct {
+	// ConfigDir is the Claude config directory, e.g. ~/.claude
+	ConfigDir string
+}
+
+// NewSessionStore creates a store for configDir, or for the default Claude
+// config directory when configDir is empty
+func NewSessionStore(configDir string) *SessionStore {
+	if configDir == "" {
+		configDir = ClaudeConfigDir()
+	}
+	return &SessionStore{ConfigDir: configDir}
+}
+
+// ClaudeConfigDir returns the CLI's config directory: $CLAUDE_CONFIG_DIR or ~/.claude
+func ClaudeConfigDir() string {
+	if dir := os.Getenv("CLAUDE_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".claude"
+	}
+	return filepath.Join(home, ".claude")
+}
+
+// ListSessions lists the sessions of a project in the default config directory
+func ListSessions(projectDir string) ([]SessionInfo, error) {
+	return NewSessionStore("").ListSessions(projectDir)
+}
+
+// LoadSession loads the messages of a session from the default config directory
+func LoadSession(id string) ([]Message, error) {
+	return NewSessionStore("").LoadSession(id)
+}
+
+// GetSessionInfo returns the metadata of a session from the default config directory
+func GetSessionInfo(id string) (*SessionInfo, error) {
+	return NewSessionStore("").GetSessionInfo(id)
+}
+
+// ListSessions lists the sessions of the project rooted at projectDir (the working
+// directory the CLI ran in; empty means the current directory), most recent first.
+// Transcripts that cannot be read are left out.
+func (s *SessionStore) ListSessions(projectDir string) ([]SessionInfo, error) {
+	if projectDir == "" {
+		var err error
+		if projectDir, err = os.Getwd(); err != nil {
+			return nil, &ClaudeSDKError{Message: "failed to determine working directory", Cause: err}
+		}
+	}
+	absDir, err := filepath.Abs(projectDir)
+	if err != nil {
+		return nil, &ClaudeSDKError{Message: "invalid project directory", Cause: err}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.projectsDir(), projectDirName(absDir), "*.jsonl"))
+	if err != nil {
+		return nil, &ClaudeSDKError{Message: "failed to list sessions", Cause: err}
+	}
+
+	sessions := make([]SessionInfo, 0, len(paths))
+	for _, path := range paths {
+		_, info, err := readTranscript(path)
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, *info)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// LoadSession loads the messages of a session, parsed like CLI output
+func (s *SessionStore) LoadSession(id string) ([]Message, error) {
+	path, err := s.findTranscript(id)
+	if err != nil {
+		return nil, err
+	}
+	messages, _, err := readTranscript(path)
+	return messages, err
+}
+
+// GetSessionInfo returns the metadata of a session
+func (s *SessionStore) GetSessionInfo(id string) (*SessionInfo, error) {
+	path, err := s.findTranscript(id)
+	if err != nil {
+		return nil, err
+	}
+	_, info, err := readTranscript(path)
+	return info, err
+}
+
+func (s *SessionStore) projectsDir() string {
+	return filepath.Join(s.ConfigDir, "projects")
+}
+
+func (s *SessionStore) findTranscript(id string) (string, error) {
+	// The ID becomes part of a glob pattern, so it must not contain metacharacters
+	if !sessionIDPattern.MatchString(id) {
+		return "", &SessionNotFoundError{ID: id}
+	}
+	paths, err := filepath.Glob(filepath.Join(s.projectsDir(), "*", id+".jsonl"))
+	if err != nil || len(paths) == 0 {
+		return "", &SessionNotFoundError{ID: id}
+	}
+	return paths[0], nil
+}
+
+// projectDirName encodes a project path the way the CLI names its transcript directory
+func projectDirName(projectDir string) string {
+	return projectDirPattern.ReplaceAllString(projectDir, "-")
+}
+
+// readTranscript parses a transcript file into messages and session metadata.
+// A last line that is not valid JSON is ignored: the CLI may be writing it.
+func readTranscript(path string) ([]Message, *SessionInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, &ClaudeSDKError{Message: fmt.Sprintf("failed to open transcript %s", path), Cause: err}
+	}
+	defer file.Close()
+
+	info := &SessionInfo{
+		ID:   strings.TrimSuffix(filepath.Base(path), ".jsonl"),
+		Path: path,
+	}
+	var messages []Message
+	// The CLI writes one line per content block, repeating the message usage
+	countedUsage := make(map[string]bool)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTranscriptLineSize)
+	var decodeErr error
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if decodeErr != nil {
+			return nil, nil, decodeErr
+		}
+
+		var rawMessage map[string]interface{}
+		if err := json.Unmarshal(line, &rawMessage); err != nil {
+			decodeErr = &CLIJSONDecodeError{Data: string(line), Cause: err}
+			continue
+		}
+
+		message, err := parseTranscriptEntry(rawMessage, info, countedUsage)
+		if err != nil {
+			return nil, nil, err
+		}
+		if message != nil {
+			messages = append(messages, message)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, &ClaudeSDKError{Message: fmt.Sprintf("failed to read transcript %s", path), Cause: err}
+	}
+
+	info.MessageCount = len(messages)
+	return messages, info, nil
+}
+
+// parseTranscriptEntry converts a transcript line into a Message, updating the
+// session metadata. Bookkeeping entries (summaries, attachments, queue operations)
+// update metadata only and yield a nil Message.
+func parseTranscriptEntry(rawMessage map[string]interface{}, info *SessionInfo, countedUsage map[string]bool) (Message, error) {
+	entryType, _ := rawMessage["type"].(string)
+	if entryType == "summary" {
+		if summary, ok := rawMessage["summary"].(string); ok {
+			info.Summary = summary
+		}
+		return nil, nil
+	}
+
+	switch MessageType(entryType) {
+	case MessageTypeUser, MessageTypeAssistant, MessageTypeSystem, MessageTypeResult:
+	default:
+		return nil, nil
+	}
+
+	// Transcripts use camelCase keys where stream-json output uses snake_case
+	if _, ok := rawMessage["session_id"]; !ok {
+		rawMessage["session_id"] = rawMessage["sessionId"]
+	}
+	if _, ok := rawMessage["tool_use_result"]; !ok {
+		if result, ok := rawMessage["toolUseResult"]; ok {
+			rawMessage["tool_use_result"] = result
+		}
+	}
+
+	message, err := parseMessage(rawMessage)
+	if err != nil {
+		return nil, err
+	}
+	updateSessionInfo(info, rawMessage, message, countedUsage)
+	return message, nil
+}
+
+func updateSessionInfo(info *SessionInfo, rawMessage map[string]interface{}, message Message, countedUsage map[string]bool) {
+	// parseMessage falls back to the current time, which must not count as activity
+	if raw, ok := rawMessage["timestamp"].(string); ok {
+		if timestamp, err := time.Parse(time.RFC3339, raw); err == nil {
+			if info.CreatedAt.IsZero() || timestamp.Before(info.CreatedAt) {
+				info.CreatedAt = timestamp
+			}
+			if timestamp.After(info.UpdatedAt) {
+				info.UpdatedAt = timestamp
+			}
+		}
+	}
+	if cwd, ok := rawMessage["cwd"].(string); ok && info.Cwd == "" {
+		info.Cwd = cwd
+	}
+
+	// Lines repeating an assistant message already counted carry its usage and cost again
+	msgMap, _ := rawMessage["message"].(map[string]interface{})
+	id, _ := msgMap["id"].(string)
+	repeated := id != "" && countedUsage[id]
+	if id != "" {
+		countedUsage[id] = true
+	}
+	if cost, ok := rawMessage["costUSD"].(float64); ok && !repeated {
+		info.CostUSD += cost
+	}
+
+	switch m := message.(type) {
+	case *UserMessage:
+		isMeta, _ := rawMessage["isMeta"].(bool)
+		if info.FirstPrompt == "" && !isMeta {
+			info.FirstPrompt = firstText(m.Content())
+		}
+	case *AssistantMessage:
+		if msgMap != nil {
+			if model, ok := msgMap["model"].(string); ok && model != "" {
+				info.Model = model
+			}
+			if usage := parseUsage(msgMap); usage != nil && !repeated {
+				info.Usage.InputTokens += usage.InputTokens
+				info.Usage.OutputTokens += usage.OutputTokens
+			}
+		}
+	case *ResultMessage:
+		if m.TotalCostUSD != nil {
+			info.CostUSD = *m.TotalCostUSD
+		}
+	}
+}
+
+// firstText returns the text of the first text block
+func firstText(blocks []ContentBlock) string {
+	for _, block := range blocks {
+		if text, ok := block.(*TextBlock); ok {
+			return text.Text
+		}
+	}
+	return ""
+}
+
+// Fork returns a copy of the options that resumes sessionID in a new, forked
+// session, leaving the original transcript untouched. A nil receiver starts
+// from empty options.
+func (o *Options) Fork(sessionID string) *Options {
+	var forked Options
+	if o != nil {
+		forked = *o
+	}
+	forked.Resume = &sessionID
+	forked.Continue = nil
+	fork := true
+	forked.ForkSession = &fork
+	return &forked
+}
+
+// SessionIDFromMessages returns the session ID reported by the init SystemMessage,
+// falling back to the ResultMessage. When forking, this is the new session's ID.
+func SessionIDFromMessages(messages []Message) string {
+	for _, message := range messages {
+		if system, ok := message.(*SystemMessage); ok && system.Subtype == "init" && system.SessionID != "" {
+			return system.SessionID
+		}
+	}
+	if result := lastResultMessage(messages); result != nil {
+		return result.SessionID
+	}
+	return ""
+}
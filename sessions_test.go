@@ -0,0 +1,223 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTranscript = `{"type":"queue-operation","operation":"enqueue","sessionId":"abc-123","timestamp":"2025-01-02T10:00:00.000Z"}
+{"parentUuid":null,"isSidechain":false,"type":"user","isMeta":true,"message":{"role":"user","content":"<local-command-caveat>"},"uuid":"u0","timestamp":"2025-01-02T10:00:00.500Z","cwd":"/work/project","sessionId":"abc-123"}
+{"parentUuid":"u0","isSidechain":false,"type":"user","message":{"role":"user","content":"List the files"},"uuid":"u1","timestamp":"2025-01-02T10:00:01.000Z","cwd":"/work/project","sessionId":"abc-123"}
+{"type":"attachment","uuid":"a1","sessionId":"abc-123"}
+{"parentUuid":"u1","type":"assistant","message":{"id":"msg_1","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"Let me look."}],"usage":{"input_tokens":10,"output_tokens":5}},"uuid":"a2","timestamp":"2025-01-02T10:00:02.000Z","sessionId":"abc-123","costUSD":0.01}
+{"parentUuid":"a2","type":"assistant","message":{"id":"msg_1","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"tool_use","id":"toolu_1","name":"LS","input":{"path":"/work/project"}}],"usage":{"input_tokens":10,"output_tokens":5}},"uuid":"a3","timestamp":"2025-01-02T10:00:02.100Z","sessionId":"abc-123","costUSD":0.01}
+{"parentUuid":"a3","type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"main.go"}]},"toolUseResult":"main.go","uuid":"u2","timestamp":"2025-01-02T10:00:03.000Z","sessionId":"abc-123"}
+{"type":"summary","summary":"Listing project files","leafUuid":"u2"}
+`
+
+func writeTranscript(t *testing.T, configDir, projectDir, id, content string) string {
+	t.Helper()
+	dir := filepath.Join(configDir, "projects", projectDirName(projectDir))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("Failed to create project directory: %v", err)
+	}
+	path := filepath.Join(dir, id+".jsonl")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write transcript: %v", err)
+	}
+	return path
+}
+
+func TestProjectDirName(t *testing.T) {
+	if name := projectDirName("/Users/me/my_project.v2"); name != "-Users-me-my-project-v2" {
+		t.Errorf("Unexpected project directory name: %s", name)
+	}
+}
+
+func TestLoadSession(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	writeTranscript(t, store.ConfigDir, "/work/project", "abc-123", testTranscript)
+
+	messages, err := store.LoadSession("abc-123")
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if len(messages) != 5 {
+		t.Fatalf("Expected 5 messages, got %d", len(messages))
+	}
+	first, ok := messages[0].(*UserMessage)
+	if !ok || first.SessionID != "abc-123" {
+		t.Errorf("Expected user message in session abc-123, got %#v", messages[0])
+	}
+
+	toolUse, ok := messages[3].Content()[0].(*ToolUseBlock)
+	if !ok || toolUse.Name != ToolLS {
+		t.Errorf("Expected LS tool use, got %#v", messages[3].Content()[0])
+	}
+	user, ok := messages[4].(*UserMessage)
+	if !ok || user.ToolUseResult != "main.go" {
+		t.Errorf("Expected tool use result on user message, got %#v", messages[4])
+	}
+
+	_, err = store.LoadSession("missing")
+	var notFound *SessionNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("Expected SessionNotFoundError, got %v", err)
+	}
+	if _, err := store.LoadSession("../abc-123"); !errors.As(err, &notFound) {
+		t.Errorf("Expected SessionNotFoundError for path-like ID, got %v", err)
+	}
+	for _, id := range []string{"*", "abc-*", "abc-12[0-9]", "?bc-123"} {
+		if _, err := store.LoadSession(id); !errors.As(err, &notFound) {
+			t.Errorf("Expected SessionNotFoundError for glob ID %q, got %v", id, err)
+		}
+	}
+}
+
+func TestLoadSessionTolerance(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	transcript := testTranscript +
+		`{"type":"user","message":{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBO"}}]},"sessionId":"abc-123"}` + "\n" +
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"te`
+	writeTranscript(t, store.ConfigDir, "/work/project", "abc-123", transcript)
+
+	messages, err := store.LoadSession("abc-123")
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	if len(messages) != 6 {
+		t.Fatalf("Expected the half-written last line to be skipped, got %d messages", len(messages))
+	}
+	image, ok := messages[5].Content()[0].(*RawBlock)
+	if !ok || image.Type() != "image" || image.Data["source"] == nil {
+		t.Errorf("Expected the image block to be kept raw, got %#v", messages[5].Content()[0])
+	}
+
+	info, err := store.GetSessionInfo("abc-123")
+	if err != nil {
+		t.Fatalf("GetSessionInfo failed: %v", err)
+	}
+	if got := info.UpdatedAt.Format("15:04:05.000"); got != "10:00:03.000" {
+		t.Errorf("Expected a message without timestamp to leave UpdatedAt alone, got %s", got)
+	}
+
+	writeTranscript(t, store.ConfigDir, "/work/project", "broken", "not json\n"+testTranscript)
+	if _, err := store.LoadSession("broken"); err == nil {
+		t.Error("Expected an error for an invalid line before the end")
+	}
+	sessions, err := store.ListSessions("/work/project")
+	if err != nil || len(sessions) != 1 || sessions[0].ID != "abc-123" {
+		t.Errorf("Expected the unreadable transcript to be left out, got %v, %v", sessions, err)
+	}
+}
+
+func TestGetSessionInfo(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	path := writeTranscript(t, store.ConfigDir, "/work/project", "abc-123", testTranscript)
+
+	info, err := store.GetSessionInfo("abc-123")
+	if err != nil {
+		t.Fatalf("GetSessionInfo failed: %v", err)
+	}
+	if info.ID != "abc-123" || info.Path != path {
+		t.Errorf("Unexpected identity: %s %s", info.ID, info.Path)
+	}
+	if info.FirstPrompt != "List the files" {
+		t.Errorf("Expected first prompt to skip meta messages, got %q", info.FirstPrompt)
+	}
+	if info.Summary != "Listing project files" {
+		t.Errorf("Unexpected summary: %q", info.Summary)
+	}
+	if info.Cwd != "/work/project" || info.Model != "claude-sonnet-4-5" {
+		t.Errorf("Unexpected cwd or model: %s %s", info.Cwd, info.Model)
+	}
+	if info.Usage.InputTokens != 10 || info.Usage.OutputTokens != 5 {
+		t.Errorf("Expected usage counted once per message, got %+v", info.Usage)
+	}
+	if info.CostUSD < 0.0099 || info.CostUSD > 0.0101 {
+		t.Errorf("Expected cost counted once per message, got %f", info.CostUSD)
+	}
+	if info.MessageCount != 5 {
+		t.Errorf("Expected 5 messages, got %d", info.MessageCount)
+	}
+	if got := info.CreatedAt.Format("15:04:05.000"); got != "10:00:00.500" {
+		t.Errorf("Unexpected CreatedAt: %s", got)
+	}
+	if got := info.UpdatedAt.Format("15:04:05.000"); got != "10:00:03.000" {
+		t.Errorf("Unexpected UpdatedAt: %s", got)
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	writeTranscript(t, store.ConfigDir, "/work/project", "older", testTranscript)
+	newer := strings.ReplaceAll(testTranscript, "2025-01-02", "2025-02-01")
+	writeTranscript(t, store.ConfigDir, "/work/project", "newer", newer)
+	writeTranscript(t, store.ConfigDir, "/work/other", "unrelated", testTranscript)
+
+	sessions, err := store.ListSessions("/work/project")
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(sessions))
+	}
+	if sessions[0].ID != "newer" || sessions[1].ID != "older" {
+		t.Errorf("Expected most recent session first, got %s, %s", sessions[0].ID, sessions[1].ID)
+	}
+
+	sessions, err = store.ListSessions("/work/none")
+	if err != nil {
+		t.Fatalf("ListSessions failed for unknown project: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("Expected no sessions, got %d", len(sessions))
+	}
+}
+
+func TestClaudeConfigDir(t *testing.T) {
+	t.Setenv("CLAUDE_CONFIG_DIR", "/custom/claude")
+	if dir := ClaudeConfigDir(); dir != "/custom/claude" {
+		t.Errorf("Expected CLAUDE_CONFIG_DIR to win, got %s", dir)
+	}
+}
+
+func TestForkSession(t *testing.T) {
+	cli := writeFakeCLI(t, `
+cat > /dev/null
+case "$*" in
+*"--resume parent-1 --fork-session"*) id=forked-1 ;;
+*) id=parent-1 ;;
+esac
+echo '{"type":"system","subtype":"init","session_id":"'$id'"}'
+echo '{"type":"result","subtype":"success","session_id":"'$id'","result":"ok"}'
+`)
+	model := "sonnet"
+	base := &Options{Executable: &cli, Model: &model, Continue: boolPtr(true)}
+	forked := base.Fork("parent-1")
+	if base.Resume != nil || base.ForkSession != nil {
+		t.Error("Expected Fork to leave the original options untouched")
+	}
+	if forked.Continue != nil || *forked.Model != "sonnet" {
+		t.Errorf("Expected Fork to keep other options and clear Continue, got %+v", forked)
+	}
+
+	messages, err := Query(context.Background(), "Try another approach", forked)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if id := SessionIDFromMessages(messages); id != "forked-1" {
+		t.Errorf("Expected forked session ID, got %q", id)
+	}
+
+	if !invalidFields((&Options{ForkSession: boolPtr(true)}).Validate())["ForkSession"] {
+		t.Error("Expected ForkSession without Resume or Continue to be rejected")
+	}
+	if id := SessionIDFromMessages([]Message{&ResultMessage{SessionID: "from-result"}}); id != "from-result" {
+		t.Errorf("Expected fallback to result session ID, got %q", id)
+	}
+}
@@ -0,0 +1,283 @@
+package claudecode
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// stderrTailSize bounds how much CLI stderr is retained for error reporting
+const stderrTailSize = 64 * 1024
+
+// maxOutputLineSize bounds a single line of CLI or MCP server output; large
+// tool results and tool lists easily exceed bufio.Scanner's 64KB default
+const maxOutputLineSize = 16 * 1024 * 1024
+
+// stderrDrainTimeout bounds how long we wait for stderr to reach EOF once stdout is done
+const stderrDrainTimeout = 2 * time.Second
+
+// StallKind identifies which timeout detected a stalled CLI process
+type StallKind string
+
+const (
+	// StallKindIdle means no output line was received within Options.IdleTimeout
+	StallKindIdle StallKind = "idle"
+	// StallKindTurn means a conversation turn did not finish within Options.TurnTimeout
+	StallKindTurn StallKind = "turn"
+)
+
+// stderrTail captures the most recent output written to the CLI's stderr.
+// It is drained on a background goroutine so the CLI never blocks on a full pipe.
+type stderrTail struct {
+	mu   sync.Mutex
+	buf  []byte
+	done chan struct{}
+}
+
+func captureStderr(reader io.Reader) *stderrTail {
+	tail := &stderrTail{done: make(chan struct{})}
+	go func() {
+		defer close(tail.done)
+		_, _ = io.Copy(tail, reader)
+	}()
+	return tail
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > stderrTailSize {
+		trimmed := make([]byte, stderrTailSize)
+		copy(trimmed, t.buf[len(t.buf)-stderrTailSize:])
+		t.buf = trimmed
+	}
+	return len(p), nil
+}
+
+// String returns the captured stderr tail
+func (t *stderrTail) String() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// drain waits for stderr to reach EOF, giving up after timeout
+func (t *stderrTail) drain(timeout time.Duration) {
+	if t == nil {
+		return
+	}
+	select {
+	case <-t.done:
+	case <-time.After(timeout):
+	}
+}
+
+// stallWatchdog tracks the idle and per-turn deadlines while reading CLI output.
+// A turn starts when the process launches and restarts every time tool results
+// (a UserMessage) or a ResultMessage are received.
+type stallWatchdog struct {
+	idleTimeout time.Duration
+	turnTimeout time.Duration
+	idle        *time.Timer
+	turn        *time.Timer
+	lastMessage Message
+}
+
+func newStallWatchdog(options *Options) *stallWatchdog {
+	w := &stallWatchdog{}
+	if options.IdleTimeout != nil && *options.IdleTimeout > 0 {
+		w.idleTimeout = *options.IdleTimeout
+		w.idle = time.NewTimer(w.idleTimeout)
+	}
+	if options.TurnTimeout != nil && *options.TurnTimeout > 0 {
+		w.turnTimeout = *options.TurnTimeout
+		w.turn = time.NewTimer(w.turnTimeout)
+	}
+	return w
+}
+
+func (w *stallWatchdog) idleC() <-chan time.Time {
+	if w.idle == nil {
+		return nil
+	}
+	return w.idle.C
+}
+
+func (w *stallWatchdog) turnC() <-chan time.Time {
+	if w.turn == nil {
+		return nil
+	}
+	return w.turn.C
+}
+
+// observeLine records that the CLI produced output
+func (w *stallWatchdog) observeLine() {
+	resetTimer(w.idle, w.idleTimeout)
+}
+
+// pauseIdle stops the idle timer while a message is handed to the consumer,
+// so that a slow consumer is not mistaken for a stalled CLI
+func (w *stallWatchdog) pauseIdle() {
+	stopTimer(w.idle)
+}
+
+// resumeIdle restarts the idle timer once the consumer took the message
+func (w *stallWatchdog) resumeIdle() {
+	resetTimer(w.idle, w.idleTimeout)
+}
+
+// observeMessage records a parsed message and starts a new turn when appropriate
+func (w *stallWatchdog) observeMessage(message Message) {
+	w.lastMessage = message
+	switch message.(type) {
+	case *UserMessage, *ResultMessage:
+		resetTimer(w.turn, w.turnTimeout)
+	}
+}
+
+func (w *stallWatchdog) stallError(kind StallKind, stderr *stderrTail) *StallError {
+	timeout := w.idleTimeout
+	if kind == StallKindTurn {
+		timeout = w.turnTimeout
+	}
+	return &StallError{
+		Kind:        kind,
+		Timeout:     timeout,
+		LastMessage: w.lastMessage,
+		StderrTail:  stderr.String(),
+	}
+}
+
+func (w *stallWatchdog) stop() {
+	if w.idle != nil {
+		w.idle.Stop()
+	}
+	if w.turn != nil {
+		w.turn.Stop()
+	}
+}
+
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if timer == nil {
+		return
+	}
+	stopTimer(timer)
+	timer.Reset(d)
+}
+
+// stopTimer stops timer and drains a pending tick
+func stopTimer(timer *time.Timer) {
+	if timer == nil {
+		return
+	}
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
+
+// scanLines reads non-empty lines from reader on a background goroutine.
+// The goroutine exits when reader is exhausted or done is closed.
+func scanLines(reader io.Reader, done <-chan struct{}) (<-chan string, <-chan error) {
+	lines := make(chan string)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxOutputLineSize)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				continue
+			}
+			select {
+			case lines <- line:
+			case <-done:
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			errs <- err
+		}
+	}()
+
+	return lines, errs
+}
+
+// scanMessages parses stream-json output line by line, handing each message to emit.
+// It enforces Options.IdleTimeout and Options.TurnTimeout, returning a *StallError
+// when either elapses; time spent in emit does not count as idle. Lines and messages are logged to log, which is tagged
+// with the session ID once a message reports it.
+func scanMessages(ctx context.Context, reader io.Reader, options *Options, stderr *stderrTail, log *queryLogger, emit func(Message) error) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	lines, scanErrs := scanLines(reader, done)
+	watchdog := newStallWatchdog(options)
+	defer watchdog.stop()
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				select {
+				case err := <-scanErrs:
+					return &CLIConnectionError{
+						Message: "error reading CLI output",
+						Cause:   err,
+					}
+				default:
+					return nil
+				}
+			}
+			watchdog.observeLine()
+			log.line(line)
+
+			var rawMessage map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &rawMessage); err != nil {
+				log.Warn("failed to parse CLI output", "line", log.redact(line), "error", err)
+				return &CLIJSONDecodeError{
+					Data:  line,
+					Cause: err,
+				}
+			}
+
+			message, err := parseMessage(rawMessage)
+			if err != nil {
+				log.Warn("failed to parse CLI message", "line", log.redact(line), "error", err)
+				return err
+			}
+			watchdog.observeMessage(message)
+			sessionID, _ := rawMessage["session_id"].(string)
+			log.tagSession(sessionID)
+			log.message(message)
+
+			watchdog.pauseIdle()
+			if err := emit(message); err != nil {
+				return err
+			}
+			watchdog.resumeIdle()
+
+		case <-watchdog.idleC():
+			log.Warn("CLI stalled", "kind", StallKindIdle, "timeout", watchdog.idleTimeout)
+			return watchdog.stallError(StallKindIdle, stderr)
+
+		case <-watchdog.turnC():
+			log.Warn("CLI stalled", "kind", StallKindTurn, "timeout", watchdog.turnTimeout)
+			return watchdog.stallError(StallKindTurn, stderr)
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
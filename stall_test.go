@@ -0,0 +1,140 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
+
+func TestScanMessagesIdleTimeout(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	go func() {
+		_, _ = io.WriteString(writer, `{"type":"assistant","message":{"content":[{"type":"text","text":"working"}]}}`+"\n")
+	}()
+
+	stderr := &stderrTail{done: make(chan struct{})}
+	_, _ = stderr.Write([]byte("mcp server not responding"))
+
+	options := &Options{IdleTimeout: durationPtr(50 * time.Millisecond)}
+	var received []Message
+	err := scanMessages(context.Background(), reader, options, stderr, newQueryLogger(options), func(message Message) error {
+		received = append(received, message)
+		return nil
+	})
+
+	var stallErr *StallError
+	if !errors.As(err, &stallErr) {
+		t.Fatalf("Expected StallError, got %T: %v", err, err)
+	}
+	if stallErr.Kind != StallKindIdle {
+		t.Errorf("Expected idle stall, got %s", stallErr.Kind)
+	}
+	if len(received) != 1 {
+		t.Fatalf("Expected 1 message before stall, got %d", len(received))
+	}
+	if stallErr.LastMessage != received[0] {
+		t.Errorf("Expected LastMessage to be the received assistant message")
+	}
+	if stallErr.StderrTail != "mcp server not responding" {
+		t.Errorf("Expected stderr tail, got %q", stallErr.StderrTail)
+	}
+	if !strings.Contains(stallErr.Error(), "last message: assistant") {
+		t.Errorf("Expected last message type in error, got %s", stallErr.Error())
+	}
+}
+
+func TestScanMessagesTurnTimeout(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	// Keep producing assistant output so the idle timeout never fires,
+	// but never deliver tool results so the turn never completes.
+	go func() {
+		for i := 0; i < 20; i++ {
+			_, err := io.WriteString(writer, `{"type":"assistant","message":{"content":"still thinking"}}`+"\n")
+			if err != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	options := &Options{
+		IdleTimeout: durationPtr(100 * time.Millisecond),
+		TurnTimeout: durationPtr(60 * time.Millisecond),
+	}
+	err := scanMessages(context.Background(), reader, options, nil, newQueryLogger(options), func(Message) error { return nil })
+
+	var stallErr *StallError
+	if !errors.As(err, &stallErr) {
+		t.Fatalf("Expected StallError, got %T: %v", err, err)
+	}
+	if stallErr.Kind != StallKindTurn {
+		t.Errorf("Expected turn stall, got %s", stallErr.Kind)
+	}
+	if stallErr.Timeout != 60*time.Millisecond {
+		t.Errorf("Expected turn timeout in error, got %s", stallErr.Timeout)
+	}
+}
+
+func TestScanMessagesWithoutTimeouts(t *testing.T) {
+	input := strings.Join([]string{
+		`{"type":"system","subtype":"init","session_id":"abc"}`,
+		``,
+		`{"type":"result","subtype":"success","session_id":"abc","result":"done"}`,
+	}, "\n")
+
+	var received []Message
+	err := scanMessages(context.Background(), strings.NewReader(input), &Options{}, nil, newQueryLogger(nil), func(message Message) error {
+		received = append(received, message)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(received))
+	}
+}
+
+func TestScanMessagesSlowConsumer(t *testing.T) {
+	input := strings.Join([]string{
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"one"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"two"}]}}`,
+		`{"type":"result","subtype":"success","result":"done"}`,
+	}, "\n")
+
+	// The CLI has already printed everything; only the consumer is slow
+	options := &Options{IdleTimeout: durationPtr(30 * time.Millisecond)}
+	var received []Message
+	err := scanMessages(context.Background(), strings.NewReader(input), options, nil, newQueryLogger(nil), func(message Message) error {
+		received = append(received, message)
+		time.Sleep(80 * time.Millisecond)
+		return nil
+	})
+	if err != nil || len(received) != 3 {
+		t.Errorf("Expected a slow consumer not to count as a stall, got %d messages and %v", len(received), err)
+	}
+}
+
+func TestStderrTailKeepsMostRecentOutput(t *testing.T) {
+	tail := captureStderr(strings.NewReader(strings.Repeat("a", stderrTailSize) + "tail"))
+	tail.drain(time.Second)
+
+	captured := tail.String()
+	if len(captured) != stderrTailSize {
+		t.Errorf("Expected %d bytes retained, got %d", stderrTailSize, len(captured))
+	}
+	if !strings.HasSuffix(captured, "tail") {
+		t.Errorf("Expected most recent output to be retained")
+	}
+}
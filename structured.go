@@ -0,0 +1,430 @@
+package claudecode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultRepairAttempts is how many times QueryJSON asks the session to fix
+// invalid output when Options.MaxRepairAttempts is not set
+const defaultRepairAttempts = 1
+
+// QueryJSON executes a query and decodes the final result into T.
+// A JSON schema is derived from T and appended to the system prompt; the response
+// is validated against it and, when invalid, the session is resumed and asked to
+// repair its output up to Options.MaxRepairAttempts times.
+func QueryJSON[T any](ctx context.Context, prompt string, options *Options) (T, *ResultMessage, error) {
+	var zero T
+
+	schema, err := SchemaFor[T]()
+	if err != nil {
+		return zero, nil, err
+	}
+	schemaJSON, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return zero, nil, &ClaudeSDKError{Message: "failed to encode JSON schema", Cause: err}
+	}
+
+	queryOptions := prepareStructuredOptions(options, string(schemaJSON))
+	repairAttempts := defaultRepairAttempts
+	if options != nil && options.MaxRepairAttempts != nil {
+		repairAttempts = *options.MaxRepairAttempts
+	}
+
+	currentPrompt := prompt
+	for attempt := 0; ; attempt++ {
+		messages, err := Query(ctx, currentPrompt, queryOptions)
+		if err != nil {
+			return zero, nil, err
+		}
+		result := lastResultMessage(messages)
+		if result == nil {
+			return zero, nil, &ClaudeSDKError{Message: "query produced no result message"}
+		}
+		if result.IsError {
+			return zero, result, &ClaudeSDKError{Message: fmt.Sprintf("query finished with error: %s", result.Subtype)}
+		}
+
+		var resultText string
+		if result.Result != nil {
+			resultText = *result.Result
+		}
+		value, problems := decodeStructuredResult[T](resultText, schema)
+		if len(problems) == 0 {
+			return value, result, nil
+		}
+
+		if attempt >= repairAttempts || result.SessionID == "" {
+			return zero, result, &StructuredOutputError{
+				Result:   resultText,
+				Problems: problems,
+				Attempts: attempt + 1,
+			}
+		}
+
+		// Ask the same session to repair its output
+		repairOptions := *queryOptions
+		repairOptions.Resume = &result.SessionID
+		repairOptions.Continue = nil
+		// The first attempt already forked; repair within the new session
+		repairOptions.ForkSession = nil
+		queryOptions = &repairOptions
+		currentPrompt = buildRepairPrompt(problems)
+	}
+}
+
+func prepareStructuredOptions(options *Options, schemaJSON string) *Options {
+	queryOptions := Options{}
+	if options != nil {
+		queryOptions = *options
+	}
+
+	// Text output carries no session ID, which repair attempts need
+	if queryOptions.OutputFormat == nil || *queryOptions.OutputFormat == OutputFormatText {
+		streamFormat := OutputFormatStreamJSON
+		queryOptions.OutputFormat = &streamFormat
+	}
+
+	instructions := "Your final response must be a single JSON value that conforms to this JSON schema, " +
+		"with no surrounding prose or code fences:\n" + schemaJSON
+	if queryOptions.AppendSystemPrompt != nil && *queryOptions.AppendSystemPrompt != "" {
+		instructions = *queryOptions.AppendSystemPrompt + "\n\n" + instructions
+	}
+	queryOptions.AppendSystemPrompt = &instructions
+
+	return &queryOptions
+}
+
+func buildRepairPrompt(problems []string) string {
+	return "Your previous response did not conform to the required JSON schema:\n- " +
+		strings.Join(problems, "\n- ") +
+		"\nRespond again with only the corrected JSON value."
+}
+
+func lastResultMessage(messages []Message) *ResultMessage {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if result, ok := messages[i].(*ResultMessage); ok {
+			return result
+		}
+	}
+	return nil
+}
+
+// decodeStructuredResult extracts, validates and decodes a JSON value from result text
+func decodeStructuredResult[T any](resultText string, schema map[string]interface{}) (T, []string) {
+	var value T
+
+	raw := extractJSON(resultText)
+	var generic interface{}
+	if err := json.Unmarshal([]byte(raw), &generic); err != nil {
+		return value, []string{fmt.Sprintf("response is not valid JSON: %v", err)}
+	}
+
+	if problems := validateJSONSchema(generic, schema, "$"); len(problems) > 0 {
+		return value, problems
+	}
+
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return value, []string{fmt.Sprintf("response does not decode into %T: %v", value, err)}
+	}
+	return value, nil
+}
+
+// extractJSON strips code fences and surrounding prose from a JSON response
+func extractJSON(text string) string {
+	text = strings.TrimSpace(text)
+
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if newline := strings.Index(text, "\n"); newline >= 0 {
+			text = text[newline+1:]
+		}
+		if end := strings.LastIndex(text, "```"); end >= 0 {
+			text = text[:end]
+		}
+		return strings.TrimSpace(text)
+	}
+
+	if json.Valid([]byte(text)) {
+		return text
+	}
+
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return text
+	}
+	closer := "}"
+	if text[start] == '[' {
+		closer = "]"
+	}
+	if end := strings.LastIndex(text, closer); end > start {
+		return text[start : end+1]
+	}
+	return text
+}
+
+// SchemaFor derives a JSON schema from T's structure and json tags.
+// Pointer and omitempty fields are optional; a `description` struct tag
+// is copied into the schema.
+func SchemaFor[T any]() (map[string]interface{}, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	return schemaForType(t, map[reflect.Type]bool{})
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) (map[string]interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	}
+	if t.Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) ||
+		reflect.PointerTo(t).Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) {
+		// Custom marshalers can produce anything
+		return map[string]interface{}{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json represents byte slices as base64 strings
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		items, err := schemaForType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, &ClaudeSDKError{Message: fmt.Sprintf("cannot derive JSON schema for map key type %s", t.Key())}
+		}
+		values, err := schemaForType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return schemaForStruct(t, visiting)
+	default:
+		return nil, &ClaudeSDKError{Message: fmt.Sprintf("cannot derive JSON schema for type %s", t)}
+	}
+}
+
+func schemaForStruct(t reflect.Type, visiting map[reflect.Type]bool) (map[string]interface{}, error) {
+	if visiting[t] {
+		// Recursive types are left unconstrained below the first level
+		return map[string]interface{}{"type": "object"}, nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitEmpty, skip := parseJSONTag(field)
+		if skip {
+			continue
+		}
+
+		// Flatten embedded structs without a json name, as encoding/json does
+		if field.Anonymous && name == field.Name && indirectType(field.Type).Kind() == reflect.Struct {
+			embedded, err := schemaForStruct(indirectType(field.Type), visiting)
+			if err != nil {
+				return nil, err
+			}
+			for key, value := range embedded["properties"].(map[string]interface{}) {
+				properties[key] = value
+			}
+			if embeddedRequired, ok := embedded["required"].([]string); ok && field.Type.Kind() != reflect.Pointer {
+				required = append(required, embeddedRequired...)
+			}
+			continue
+		}
+
+		fieldSchema, err := schemaForType(field.Type, visiting)
+		if err != nil {
+			return nil, err
+		}
+		if description := field.Tag.Get("description"); description != "" {
+			fieldSchema["description"] = description
+		}
+		properties[name] = fieldSchema
+
+		if !omitEmpty && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+func parseJSONTag(field reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// validateJSONSchema checks a decoded JSON value against the subset of JSON schema
+// produced by SchemaFor, returning one problem description per violation
+func validateJSONSchema(value interface{}, schema map[string]interface{}, path string) []string {
+	schemaType, _ := schema["type"].(string)
+	if schemaType == "" {
+		return nil
+	}
+
+	switch schemaType {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object, got %s", path, jsonTypeName(value))}
+		}
+		return validateJSONObject(object, schema, path)
+
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected array, got %s", path, jsonTypeName(value))}
+		}
+		var problems []string
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range array {
+				problems = append(problems, validateJSONSchema(item, items, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+		return problems
+
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return []string{fmt.Sprintf("%s: expected integer, got %s", path, jsonTypeName(value))}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{fmt.Sprintf("%s: expected number, got %s", path, jsonTypeName(value))}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return []string{fmt.Sprintf("%s: expected string, got %s", path, jsonTypeName(value))}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected boolean, got %s", path, jsonTypeName(value))}
+		}
+	}
+	return nil
+}
+
+func validateJSONObject(object map[string]interface{}, schema map[string]interface{}, path string) []string {
+	var problems []string
+
+	required := map[string]bool{}
+	if names, ok := schema["required"].([]string); ok {
+		for _, name := range names {
+			required[name] = true
+			if _, present := object[name]; !present {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "." + key
+		if propertySchema, ok := properties[key].(map[string]interface{}); ok {
+			if object[key] == nil && !required[key] {
+				// null is accepted for optional properties
+				continue
+			}
+			problems = append(problems, validateJSONSchema(object[key], propertySchema, childPath)...)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				problems = append(problems, fmt.Sprintf("%s: unexpected property", childPath))
+			}
+		case map[string]interface{}:
+			problems = append(problems, validateJSONSchema(object[key], additional, childPath)...)
+		}
+	}
+
+	return problems
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
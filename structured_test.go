@@ -0,0 +1,125 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type reviewFinding struct {
+	File     string   `json:"file" description:"Path relative to the repository root"`
+	Line     int      `json:"line"`
+	Severity string   `json:"severity"`
+	Tags     []string `json:"tags,omitempty"`
+	Fix      *string  `json:"fix"`
+}
+
+type reviewReport struct {
+	Summary  string          `json:"summary"`
+	Findings []reviewFinding `json:"findings"`
+	internal string
+}
+
+func TestSchemaFor(t *testing.T) {
+	schema, err := SchemaFor[reviewReport]()
+	if err != nil {
+		t.Fatalf("SchemaFor failed: %v", err)
+	}
+
+	if schema["type"] != "object" {
+		t.Errorf("Expected object schema, got %v", schema["type"])
+	}
+	if !reflect.DeepEqual(schema["required"], []string{"findings", "summary"}) {
+		t.Errorf("Unexpected required fields: %v", schema["required"])
+	}
+	properties := schema["properties"].(map[string]interface{})
+	if _, ok := properties["internal"]; ok {
+		t.Error("Unexported fields must not appear in the schema")
+	}
+
+	items := properties["findings"].(map[string]interface{})["items"].(map[string]interface{})
+	if !reflect.DeepEqual(items["required"], []string{"file", "line", "severity"}) {
+		t.Errorf("Unexpected required finding fields: %v", items["required"])
+	}
+	itemProperties := items["properties"].(map[string]interface{})
+	file := itemProperties["file"].(map[string]interface{})
+	if file["description"] != "Path relative to the repository root" {
+		t.Errorf("Expected description tag to be copied, got %v", file["description"])
+	}
+	if itemProperties["line"].(map[string]interface{})["type"] != "integer" {
+		t.Errorf("Expected integer line, got %v", itemProperties["line"])
+	}
+
+	if _, err := SchemaFor[map[int]string](); err == nil {
+		t.Error("Expected error for non-string map keys")
+	}
+}
+
+func TestDecodeStructuredResult(t *testing.T) {
+	schema, err := SchemaFor[reviewReport]()
+	if err != nil {
+		t.Fatalf("SchemaFor failed: %v", err)
+	}
+
+	fenced := "```json\n{\"summary\":\"ok\",\"findings\":[{\"file\":\"a.go\",\"line\":3,\"severity\":\"low\",\"fix\":null}]}\n```"
+	report, problems := decodeStructuredResult[reviewReport](fenced, schema)
+	if len(problems) > 0 {
+		t.Fatalf("Unexpected problems: %v", problems)
+	}
+	if report.Summary != "ok" || len(report.Findings) != 1 || report.Findings[0].Line != 3 {
+		t.Errorf("Unexpected decoded report: %+v", report)
+	}
+
+	withProse := `Here is the report: {"summary":"ok","findings":[]} Let me know if you need more.`
+	if _, problems := decodeStructuredResult[reviewReport](withProse, schema); len(problems) > 0 {
+		t.Errorf("Expected JSON to be extracted from prose, got %v", problems)
+	}
+
+	invalid := `{"summary":1,"findings":[{"file":"a.go","line":2.5,"extra":true}]}`
+	_, problems = decodeStructuredResult[reviewReport](invalid, schema)
+	expected := []string{
+		`$.findings[0]: missing required property "severity"`,
+		`$.findings[0].extra: unexpected property`,
+		`$.findings[0].line: expected integer, got number`,
+		`$.summary: expected string, got number`,
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Unexpected problems:\n got: %v\nwant: %v", problems, expected)
+	}
+}
+
+func TestQueryJSONRepairsInvalidOutput(t *testing.T) {
+	cli := writeFakeCLI(t, `
+cat > /dev/null
+case "$*" in
+*--resume\ session-1*)
+	echo '{"type":"result","subtype":"success","session_id":"session-1","result":"{\"summary\":\"fixed\",\"findings\":[]}"}'
+	;;
+*)
+	echo '{"type":"result","subtype":"success","session_id":"session-1","result":"{\"summary\":\"missing findings\"}"}'
+	;;
+esac
+`)
+
+	report, result, err := QueryJSON[reviewReport](context.Background(), "review", &Options{Executable: &cli})
+	if err != nil {
+		t.Fatalf("QueryJSON failed: %v", err)
+	}
+	if report.Summary != "fixed" {
+		t.Errorf("Expected repaired output, got %+v", report)
+	}
+	if result == nil || result.SessionID != "session-1" {
+		t.Errorf("Expected final result message, got %+v", result)
+	}
+
+	noRepair := 0
+	_, _, err = QueryJSON[reviewReport](context.Background(), "review", &Options{Executable: &cli, MaxRepairAttempts: &noRepair})
+	var structuredErr *StructuredOutputError
+	if !errors.As(err, &structuredErr) {
+		t.Fatalf("Expected StructuredOutputError, got %T: %v", err, err)
+	}
+	if structuredErr.Attempts != 1 || len(structuredErr.Problems) != 1 {
+		t.Errorf("Unexpected error details: %+v", structuredErr)
+	}
+}
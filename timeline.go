@@ -0,0 +1,201 @@
+package claudecode
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ToolCallSpan is one tool call on a timeline
+type ToolCallSpan struct {
+	ID   string `json:"id"`
+	Tool string `json:"tool"`
+	// Input summarizes the tool input, e.g. the command of a Bash call
+	Input string    `json:"input"`
+	Start time.Time `json:"start"`
+	// End is zero while the call is pending
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration_ns"`
+	IsError  bool          `json:"is_error"`
+	Pending  bool          `json:"pending,omitempty"`
+	// ParentID is the ID of the Task call whose subagent made this call; empty for the main agent
+	ParentID string `json:"parent_id,omitempty"`
+	// Subagent describes the owning subagent; empty for the main agent
+	Subagent string `json:"subagent,omitempty"`
+	Depth    int    `json:"depth"`
+}
+
+// ToolTimeline lists the tool calls of a run in the order they were made.
+//
+// Times are message timestamps. Transcripts record when each message happened,
+// but stream-json output carries no timestamps, so messages parsed from it are
+// stamped with the time they were parsed. That is accurate for a timeline built
+// while the query runs, e.g. from QueryStream, and meaningless for output saved
+// and parsed later, whose calls then get the times the file was read.
+type ToolTimeline struct {
+	Calls []ToolCallSpan `json:"calls"`
+	Start time.Time      `json:"start"`
+	End   time.Time      `json:"end"`
+}
+
+// TimelineAnalyzer builds a ToolTimeline from a message stream, e.g. QueryStream output
+type TimelineAnalyzer struct {
+	messages []Message
+}
+
+// NewTimelineAnalyzer creates an empty analyzer
+func NewTimelineAnalyzer() *TimelineAnalyzer {
+	return &TimelineAnalyzer{}
+}
+
+// Add records a message
+func (a *TimelineAnalyzer) Add(message Message) {
+	a.messages = append(a.messages, message)
+}
+
+// Timeline returns the timeline of the messages added so far
+func (a *TimelineAnalyzer) Timeline() *ToolTimeline {
+	return BuildToolTimeline(a.messages)
+}
+
+// BuildToolTimeline builds the tool call timeline of a message list. Start and end
+// times come from the timestamps of the messages carrying the tool use and its
+// result; see ToolTimeline for where those timestamps come from.
+func BuildToolTimeline(messages []Message) *ToolTimeline {
+	timeline := &ToolTimeline{Calls: []ToolCallSpan{}}
+	for _, message := range messages {
+		if _, ok := message.(*StreamEvent); ok {
+			continue
+		}
+		timestamp := message.Timestamp()
+		if timestamp.IsZero() {
+			continue
+		}
+		if timeline.Start.IsZero() || timestamp.Before(timeline.Start) {
+			timeline.Start = timestamp
+		}
+		if timestamp.After(timeline.End) {
+			timeline.End = timestamp
+		}
+	}
+
+	for _, call := range BuildTranscriptTree(messages).ToolCalls() {
+		span := ToolCallSpan{
+			ID:    call.Use.ID,
+			Tool:  call.Use.Name,
+			Input: call.Use.Summary(),
+			Start: call.Caller.Message.Timestamp(),
+			Depth: call.Caller.Depth,
+		}
+		if parent := call.Caller.Parent; parent != nil {
+			span.ParentID = parent.Use.ID
+			span.Subagent = parent.Use.Summary()
+		}
+		if call.Result != nil {
+			span.End = call.ResultNode.Message.Timestamp()
+			span.Duration = span.End.Sub(span.Start)
+			span.IsError = call.Result.IsError
+		} else {
+			span.Pending = true
+		}
+		timeline.Calls = append(timeline.Calls, span)
+	}
+
+	return timeline
+}
+
+// Errors returns the calls that returned an error
+func (t *ToolTimeline) Errors() []ToolCallSpan {
+	var spans []ToolCallSpan
+	for _, span := range t.Calls {
+		if span.IsError {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// TotalByTool sums call durations per tool name
+func (t *ToolTimeline) TotalByTool() map[string]time.Duration {
+	totals := make(map[string]time.Duration)
+	for _, span := range t.Calls {
+		totals[span.Tool] += span.Duration
+	}
+	return totals
+}
+
+// WriteJSON writes the timeline as indented JSON
+func (t *ToolTimeline) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(t)
+}
+
+// chromeTraceEvent is an event in the Chrome trace-event format
+// (https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU)
+type chromeTraceEvent struct {
+	Name     string                 `json:"name"`
+	Category string                 `json:"cat,omitempty"`
+	Phase    string                 `json:"ph"`
+	TS       int64                  `json:"ts"`
+	Duration int64                  `json:"dur,omitempty"`
+	PID      int                    `json:"pid"`
+	TID      int                    `json:"tid"`
+	Args     map[string]interface{} `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes the timeline in Chrome trace-event format, loadable in
+// chrome://tracing or Perfetto. The main agent and each subagent get their own track;
+// pending calls extend to the end of the timeline.
+func (t *ToolTimeline) WriteChromeTrace(w io.Writer) error {
+	tracks := map[string]int{"": 0}
+	events := []chromeTraceEvent{{
+		Name: "thread_name", Phase: "M", PID: 1, TID: 0,
+		Args: map[string]interface{}{"name": "main agent"},
+	}}
+
+	for _, span := range t.Calls {
+		tid, ok := tracks[span.ParentID]
+		if !ok {
+			tid = len(tracks)
+			tracks[span.ParentID] = tid
+			events = append(events, chromeTraceEvent{
+				Name: "thread_name", Phase: "M", PID: 1, TID: tid,
+				Args: map[string]interface{}{"name": fmt.Sprintf("subagent: %s", span.Subagent)},
+			})
+		}
+
+		end := span.End
+		if span.Pending {
+			end = t.End
+		}
+		duration := end.Sub(span.Start).Microseconds()
+		if duration < 1 {
+			// Zero-length complete events are hidden by most viewers
+			duration = 1
+		}
+		args := map[string]interface{}{"id": span.ID, "input": span.Input}
+		if span.IsError {
+			args["error"] = true
+		}
+		if span.Pending {
+			args["pending"] = true
+		}
+		events = append(events, chromeTraceEvent{
+			Name:     span.Tool,
+			Category: "tool",
+			Phase:    "X",
+			TS:       span.Start.Sub(t.Start).Microseconds(),
+			Duration: duration,
+			PID:      1,
+			TID:      tid,
+			Args:     args,
+		})
+	}
+
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	})
+}
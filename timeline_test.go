@@ -0,0 +1,147 @@
+package claudecode
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func timedTranscript() []Message {
+	start := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	at := func(seconds float64) time.Time {
+		return start.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	task := "toolu_task"
+	return []Message{
+		&UserMessage{CreatedAt: at(0), ContentBlocks: []ContentBlock{&TextBlock{Text: "Review"}}},
+		&AssistantMessage{CreatedAt: at(1), ContentBlocks: []ContentBlock{
+			&ToolUseBlock{ID: "toolu_bash", Name: ToolBash, Input: map[string]interface{}{"command": "go test\n  ./..."}},
+			&ToolUseBlock{ID: "toolu_task", Name: ToolTask, Input: map[string]interface{}{
+				"description": "Find TODOs", "prompt": "...", "subagent_type": "general-purpose",
+			}},
+		}},
+		&UserMessage{CreatedAt: at(4), ContentBlocks: []ContentBlock{
+			&ToolResultBlock{ToolUseID: "toolu_bash", Content: "FAIL", IsError: true},
+		}},
+		&AssistantMessage{CreatedAt: at(5), ParentToolUseID: &task, ContentBlocks: []ContentBlock{
+			&ToolUseBlock{ID: "toolu_grep", Name: ToolGrep, Input: map[string]interface{}{"pattern": "TODO"}},
+		}},
+		&UserMessage{CreatedAt: at(5.5), ParentToolUseID: &task, ContentBlocks: []ContentBlock{
+			&ToolResultBlock{ToolUseID: "toolu_grep", Content: "none"},
+		}},
+		&AssistantMessage{CreatedAt: at(6), ContentBlocks: []ContentBlock{
+			&ToolUseBlock{ID: "toolu_read", Name: ToolRead, Input: map[string]interface{}{"file_path": "main.go"}},
+		}},
+		&UserMessage{CreatedAt: at(7), ContentBlocks: []ContentBlock{
+			&ToolResultBlock{ToolUseID: "toolu_task", Content: "No TODOs"},
+		}},
+	}
+}
+
+func TestBuildToolTimeline(t *testing.T) {
+	analyzer := NewTimelineAnalyzer()
+	for _, message := range timedTranscript() {
+		analyzer.Add(message)
+	}
+	timeline := analyzer.Timeline()
+
+	if len(timeline.Calls) != 4 {
+		t.Fatalf("Expected 4 calls, got %d", len(timeline.Calls))
+	}
+	if timeline.End.Sub(timeline.Start) != 7*time.Second {
+		t.Errorf("Expected a 7s timeline, got %v", timeline.End.Sub(timeline.Start))
+	}
+
+	bash := timeline.Calls[0]
+	if bash.Tool != ToolBash || bash.Input != "go test ./..." || bash.Duration != 3*time.Second || !bash.IsError {
+		t.Errorf("Unexpected Bash span: %+v", bash)
+	}
+	task := timeline.Calls[1]
+	if task.Input != "Find TODOs (general-purpose)" || task.Duration != 6*time.Second {
+		t.Errorf("Unexpected Task span: %+v", task)
+	}
+	grep := timeline.Calls[2]
+	if grep.ParentID != "toolu_task" || grep.Subagent != "Find TODOs (general-purpose)" || grep.Depth != 1 {
+		t.Errorf("Expected Grep span owned by the subagent, got %+v", grep)
+	}
+	if grep.Duration != 500*time.Millisecond {
+		t.Errorf("Expected 500ms Grep span, got %v", grep.Duration)
+	}
+	read := timeline.Calls[3]
+	if !read.Pending || read.Duration != 0 {
+		t.Errorf("Expected pending Read span, got %+v", read)
+	}
+
+	if errs := timeline.Errors(); len(errs) != 1 || errs[0].ID != "toolu_bash" {
+		t.Errorf("Expected Bash to be the only error, got %v", errs)
+	}
+	if total := timeline.TotalByTool()[ToolGrep]; total != 500*time.Millisecond {
+		t.Errorf("Unexpected Grep total: %v", total)
+	}
+
+	var buf bytes.Buffer
+	if err := timeline.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded ToolTimeline
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Timeline JSON did not round-trip: %v", err)
+	}
+	if len(decoded.Calls) != 4 || decoded.Calls[0].Duration != 3*time.Second {
+		t.Errorf("Unexpected decoded timeline: %+v", decoded)
+	}
+}
+
+func TestToolTimelineChromeTrace(t *testing.T) {
+	timeline := BuildToolTimeline(timedTranscript())
+
+	var buf bytes.Buffer
+	if err := timeline.WriteChromeTrace(&buf); err != nil {
+		t.Fatalf("WriteChromeTrace failed: %v", err)
+	}
+	var trace struct {
+		TraceEvents []chromeTraceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("Invalid trace JSON: %v", err)
+	}
+
+	var threads, spans []chromeTraceEvent
+	for _, event := range trace.TraceEvents {
+		switch event.Phase {
+		case "M":
+			threads = append(threads, event)
+		case "X":
+			spans = append(spans, event)
+		}
+	}
+	if len(threads) != 2 || !strings.HasPrefix(threads[1].Args["name"].(string), "subagent: Find TODOs") {
+		t.Errorf("Expected main and subagent tracks, got %+v", threads)
+	}
+	if len(spans) != 4 {
+		t.Fatalf("Expected 4 spans, got %d", len(spans))
+	}
+	if spans[0].TS != 1000000 || spans[0].Duration != 3000000 || spans[0].Args["error"] != true {
+		t.Errorf("Unexpected Bash event: %+v", spans[0])
+	}
+	if spans[2].TID != threads[1].TID {
+		t.Errorf("Expected Grep on the subagent track, got tid %d", spans[2].TID)
+	}
+	if spans[3].Duration != 1000000 || spans[3].Args["pending"] != true {
+		t.Errorf("Expected pending Read to extend to the end of the run, got %+v", spans[3])
+	}
+}
+
+func TestToolUseSummary(t *testing.T) {
+	long := strings.Repeat("x", 200)
+	summary := (&ToolUseBlock{Name: ToolBash, Input: map[string]interface{}{"command": long}}).Summary()
+	if len([]rune(summary)) != maxInputSummaryLength || !strings.HasSuffix(summary, "…") {
+		t.Errorf("Expected truncated summary, got %q", summary)
+	}
+	summary = (&ToolUseBlock{Name: "mcp__github__create_issue", Input: map[string]interface{}{"title": "Bug"}}).Summary()
+	if summary != `{"title":"Bug"}` {
+		t.Errorf("Expected JSON summary for unknown tools, got %q", summary)
+	}
+}
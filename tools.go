@@ -0,0 +1,448 @@
+package claudecode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Built-in Claude Code tool names, usable in AllowedTools and DisallowedTools
+const (
+	ToolBash         = "Bash"
+	ToolRead         = "Read"
+	ToolWrite        = "Write"
+	ToolEdit         = "Edit"
+	ToolMultiEdit    = "MultiEdit"
+	ToolGlob         = "Glob"
+	ToolGrep         = "Grep"
+	ToolLS           = "LS"
+	ToolWebFetch     = "WebFetch"
+	ToolWebSearch    = "WebSearch"
+	ToolTodoWrite    = "TodoWrite"
+	ToolTask         = "Task"
+	ToolNotebookEdit = "NotebookEdit"
+	ToolExitPlanMode = "ExitPlanMode"
+)
+
+// ToolInput is implemented by the typed inputs of built-in tools
+type ToolInput interface {
+	ToolName() string
+}
+
+// BashInput is the input of the Bash tool
+type BashInput struct {
+	Command         string `json:"command"`
+	Description     string `json:"description,omitempty"`
+	Timeout         *int   `json:"timeout,omitempty"`
+	RunInBackground bool   `json:"run_in_background,omitempty"`
+}
+
+func (i *BashInput) ToolName() string { return ToolBash }
+
+// ReadInput is the input of the Read tool
+type ReadInput struct {
+	FilePath string `json:"file_path"`
+	Offset   *int   `json:"offset,omitempty"`
+	Limit    *int   `json:"limit,omitempty"`
+}
+
+func (i *ReadInput) ToolName() string { return ToolRead }
+
+// WriteInput is the input of the Write tool
+type WriteInput struct {
+	FilePath string `json:"file_path"`
+	Content  string `json:"content"`
+}
+
+func (i *WriteInput) ToolName() string { return ToolWrite }
+
+// EditInput is the input of the Edit tool
+type EditInput struct {
+	FilePath   string `json:"file_path"`
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all,omitempty"`
+}
+
+func (i *EditInput) ToolName() string { return ToolEdit }
+
+// EditOperation is a single replacement within a MultiEdit
+type EditOperation struct {
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all,omitempty"`
+}
+
+// MultiEditInput is the input of the MultiEdit tool
+type MultiEditInput struct {
+	FilePath string          `json:"file_path"`
+	Edits    []EditOperation `json:"edits"`
+}
+
+func (i *MultiEditInput) ToolName() string { return ToolMultiEdit }
+
+// GlobInput is the input of the Glob tool
+type GlobInput struct {
+	Pattern string `json:"pattern"`
+	Path    string `json:"path,omitempty"`
+}
+
+func (i *GlobInput) ToolName() string { return ToolGlob }
+
+// GrepInput is the input of the Grep tool
+type GrepInput struct {
+	Pattern         string `json:"pattern"`
+	Path            string `json:"path,omitempty"`
+	Glob            string `json:"glob,omitempty"`
+	FileType        string `json:"type,omitempty"`
+	OutputMode      string `json:"output_mode,omitempty"`
+	CaseInsensitive bool   `json:"-i,omitempty"`
+	LineNumbers     bool   `json:"-n,omitempty"`
+	ContextBefore   *int   `json:"-B,omitempty"`
+	ContextAfter    *int   `json:"-A,omitempty"`
+	Context         *int   `json:"-C,omitempty"`
+	HeadLimit       *int   `json:"head_limit,omitempty"`
+	Multiline       bool   `json:"multiline,omitempty"`
+}
+
+func (i *GrepInput) ToolName() string { return ToolGrep }
+
+// LSInput is the input of the LS tool
+type LSInput struct {
+	Path   string   `json:"path"`
+	Ignore []string `json:"ignore,omitempty"`
+}
+
+func (i *LSInput) ToolName() string { return ToolLS }
+
+// WebFetchInput is the input of the WebFetch tool
+type WebFetchInput struct {
+	URL    string `json:"url"`
+	Prompt string `json:"prompt"`
+}
+
+func (i *WebFetchInput) ToolName() string { return ToolWebFetch }
+
+// WebSearchInput is the input of the WebSearch tool
+type WebSearchInput struct {
+	Query          string   `json:"query"`
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	BlockedDomains []string `json:"blocked_domains,omitempty"`
+}
+
+func (i *WebSearchInput) ToolName() string { return ToolWebSearch }
+
+// TodoStatus represents the state of a todo item
+type TodoStatus string
+
+const (
+	TodoStatusPending    TodoStatus = "pending"
+	TodoStatusInProgress TodoStatus = "in_progress"
+	TodoStatusCompleted  TodoStatus = "completed"
+)
+
+// Todo is a single item managed by the TodoWrite tool
+type Todo struct {
+	Content    string     `json:"content"`
+	Status     TodoStatus `json:"status"`
+	ActiveForm string     `json:"activeForm,omitempty"`
+	ID         string     `json:"id,omitempty"`
+	Priority   string     `json:"priority,omitempty"`
+}
+
+// TodoWriteInput is the input of the TodoWrite tool
+type TodoWriteInput struct {
+	Todos []Todo `json:"todos"`
+}
+
+func (i *TodoWriteInput) ToolName() string { return ToolTodoWrite }
+
+// TaskInput is the input of the Task tool, which launches a subagent
+type TaskInput struct {
+	Description  string `json:"description"`
+	Prompt       string `json:"prompt"`
+	SubagentType string `json:"subagent_type,omitempty"`
+}
+
+func (i *TaskInput) ToolName() string { return ToolTask }
+
+// NotebookEditInput is the input of the NotebookEdit tool
+type NotebookEditInput struct {
+	NotebookPath string `json:"notebook_path"`
+	CellID       string `json:"cell_id,omitempty"`
+	NewSource    string `json:"new_source"`
+	CellType     string `json:"cell_type,omitempty"`
+	EditMode     string `json:"edit_mode,omitempty"`
+}
+
+func (i *NotebookEditInput) ToolName() string { return ToolNotebookEdit }
+
+// ExitPlanModeInput is the input of the ExitPlanMode tool
+type ExitPlanModeInput struct {
+	Plan string `json:"plan"`
+}
+
+func (i *ExitPlanModeInput) ToolName() string { return ToolExitPlanMode }
+
+// newToolInput returns an empty typed input for a built-in tool name
+func newToolInput(name string) ToolInput {
+	switch name {
+	case ToolBash:
+		return &BashInput{}
+	case ToolRead:
+		return &ReadInput{}
+	case ToolWrite:
+		return &WriteInput{}
+	case ToolEdit:
+		return &EditInput{}
+	case ToolMultiEdit:
+		return &MultiEditInput{}
+	case ToolGlob:
+		return &GlobInput{}
+	case ToolGrep:
+		return &GrepInput{}
+	case ToolLS:
+		return &LSInput{}
+	case ToolWebFetch:
+		return &WebFetchInput{}
+	case ToolWebSearch:
+		return &WebSearchInput{}
+	case ToolTodoWrite:
+		return &TodoWriteInput{}
+	case ToolTask:
+		return &TaskInput{}
+	case ToolNotebookEdit:
+		return &NotebookEditInput{}
+	case ToolExitPlanMode:
+		return &ExitPlanModeInput{}
+	}
+	return nil
+}
+
+// Decode converts the untyped Input into the typed input of a built-in tool,
+// e.g. *BashInput for Bash. It returns an *UnknownToolError for MCP and other
+// tools without a typed input.
+func (t *ToolUseBlock) Decode() (ToolInput, error) {
+	input := newToolInput(t.Name)
+	if input == nil {
+		return nil, &UnknownToolError{Name: t.Name}
+	}
+	if err := decodeToolValue(t.Input, input); err != nil {
+		return nil, err
+	}
+	return input, nil
+}
+
+// BashResult is the structured result of the Bash tool
+type BashResult struct {
+	Stdout      string `json:"stdout"`
+	Stderr      string `json:"stderr"`
+	Interrupted bool   `json:"interrupted"`
+	IsImage     bool   `json:"isImage,omitempty"`
+}
+
+// ReadFile describes the file content returned by the Read tool
+type ReadFile struct {
+	FilePath   string `json:"filePath"`
+	Content    string `json:"content"`
+	NumLines   int    `json:"numLines"`
+	StartLine  int    `json:"startLine"`
+	TotalLines int    `json:"totalLines"`
+}
+
+// ReadResult is the structured result of the Read tool
+type ReadResult struct {
+	Type string   `json:"type"`
+	File ReadFile `json:"file"`
+}
+
+// WriteResult is the structured result of the Write tool
+type WriteResult struct {
+	Type     string `json:"type"`
+	FilePath string `json:"filePath"`
+	Content  string `json:"content"`
+}
+
+// EditResult is the structured result of the Edit and MultiEdit tools
+type EditResult struct {
+	FilePath     string `json:"filePath"`
+	OldString    string `json:"oldString,omitempty"`
+	NewString    string `json:"newString,omitempty"`
+	OriginalFile string `json:"originalFile,omitempty"`
+	UserModified bool   `json:"userModified,omitempty"`
+	ReplaceAll   bool   `json:"replaceAll,omitempty"`
+}
+
+// GlobResult is the structured result of the Glob tool
+type GlobResult struct {
+	Filenames  []string `json:"filenames"`
+	NumFiles   int      `json:"numFiles"`
+	DurationMs int      `json:"durationMs"`
+	Truncated  bool     `json:"truncated"`
+}
+
+// GrepResult is the structured result of the Grep tool
+type GrepResult struct {
+	Mode      string   `json:"mode"`
+	Filenames []string `json:"filenames"`
+	NumFiles  int      `json:"numFiles"`
+	Content   string   `json:"content,omitempty"`
+	NumLines  int      `json:"numLines,omitempty"`
+}
+
+// WebFetchResult is the structured result of the WebFetch tool
+type WebFetchResult struct {
+	URL        string `json:"url"`
+	Code       int    `json:"code"`
+	CodeText   string `json:"codeText"`
+	Bytes      int    `json:"bytes"`
+	Result     string `json:"result"`
+	DurationMs int    `json:"durationMs"`
+}
+
+// TodoWriteResult is the structured result of the TodoWrite tool
+type TodoWriteResult struct {
+	OldTodos []Todo `json:"oldTodos"`
+	NewTodos []Todo `json:"newTodos"`
+}
+
+// TaskResult is the structured result of the Task tool
+type TaskResult struct {
+	Content           []TextBlock `json:"content"`
+	TotalDurationMs   int         `json:"totalDurationMs"`
+	TotalTokens       int         `json:"totalTokens"`
+	TotalToolUseCount int         `json:"totalToolUseCount"`
+}
+
+// DecodeToolResult converts the structured tool result reported in
+// UserMessage.ToolUseResult into the typed result of the named built-in tool,
+// e.g. *BashResult for Bash.
+func DecodeToolResult(toolName string, result interface{}) (interface{}, error) {
+	var typed interface{}
+	switch toolName {
+	case ToolBash:
+		typed = &BashResult{}
+	case ToolRead:
+		typed = &ReadResult{}
+	case ToolWrite:
+		typed = &WriteResult{}
+	case ToolEdit, ToolMultiEdit:
+		typed = &EditResult{}
+	case ToolGlob:
+		typed = &GlobResult{}
+	case ToolGrep:
+		typed = &GrepResult{}
+	case ToolWebFetch:
+		typed = &WebFetchResult{}
+	case ToolTodoWrite:
+		typed = &TodoWriteResult{}
+	case ToolTask:
+		typed = &TaskResult{}
+	default:
+		return nil, &UnknownToolError{Name: toolName}
+	}
+
+	if err := decodeToolValue(result, typed); err != nil {
+		return nil, err
+	}
+	return typed, nil
+}
+
+// Text returns the textual content of a tool result, joining text blocks
+func (t *ToolResultBlock) Text() string {
+	switch content := t.Content.(type) {
+	case string:
+		return content
+	case []interface{}:
+		var text string
+		for _, item := range content {
+			if itemMap, ok := item.(map[string]interface{}); ok {
+				if itemText, ok := itemMap["text"].(string); ok {
+					if text != "" {
+						text += "\n"
+					}
+					text += itemText
+				}
+			}
+		}
+		return text
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", t.Content)
+}
+
+// maxInputSummaryLength bounds ToolUseBlock.Summary
+const maxInputSummaryLength = 120
+
+// Summary returns a short, single-line description of the input, such as the
+// command of a Bash call or the file path of an Edit
+func (t *ToolUseBlock) Summary() string {
+	var summary string
+	input, err := t.Decode()
+	if err == nil {
+		switch in := input.(type) {
+		case *BashInput:
+			summary = in.Command
+		case *ReadInput:
+			summary = in.FilePath
+		case *WriteInput:
+			summary = in.FilePath
+		case *EditInput:
+			summary = in.FilePath
+		case *MultiEditInput:
+			summary = fmt.Sprintf("%s (%d edits)", in.FilePath, len(in.Edits))
+		case *NotebookEditInput:
+			summary = in.NotebookPath
+		case *GlobInput:
+			summary = in.Pattern
+		case *GrepInput:
+			summary = in.Pattern
+		case *LSInput:
+			summary = in.Path
+		case *WebFetchInput:
+			summary = in.URL
+		case *WebSearchInput:
+			summary = in.Query
+		case *TodoWriteInput:
+			summary = fmt.Sprintf("%d todos", len(in.Todos))
+		case *TaskInput:
+			summary = in.Description
+			if in.SubagentType != "" {
+				summary = fmt.Sprintf("%s (%s)", in.Description, in.SubagentType)
+			}
+		}
+	}
+	if summary == "" && len(t.Input) > 0 {
+		data, _ := json.Marshal(t.Input)
+		summary = string(data)
+	}
+	return truncateText(strings.Join(strings.Fields(summary), " "), maxInputSummaryLength)
+}
+
+// truncateText shortens s to at most limit runes, marking the cut with an ellipsis
+func truncateText(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// decodeToolValue converts a decoded JSON value into a typed struct
+func decodeToolValue(value interface{}, target interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return &CLIJSONDecodeError{
+			Data:  fmt.Sprintf("%v", value),
+			Cause: err,
+		}
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return &CLIJSONDecodeError{
+			Data:  string(data),
+			Cause: err,
+		}
+	}
+	return nil
+}
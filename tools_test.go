@@ -0,0 +1,128 @@
+package claudecode
+
+import (
+	"testing"
+)
+
+func TestToolUseBlockDecode(t *testing.T) {
+	block := &ToolUseBlock{
+		ID:   "tool-1",
+		Name: ToolBash,
+		Input: map[string]interface{}{
+			"command":     "go test ./...",
+			"description": "Run tests",
+			"timeout":     float64(60000),
+		},
+	}
+	input, err := block.Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	bash, ok := input.(*BashInput)
+	if !ok {
+		t.Fatalf("Expected *BashInput, got %T", input)
+	}
+	if bash.Command != "go test ./..." || bash.Timeout == nil || *bash.Timeout != 60000 {
+		t.Errorf("Unexpected bash input: %+v", bash)
+	}
+	if bash.ToolName() != ToolBash {
+		t.Errorf("Expected tool name %s, got %s", ToolBash, bash.ToolName())
+	}
+
+	multiEdit := &ToolUseBlock{
+		Name: ToolMultiEdit,
+		Input: map[string]interface{}{
+			"file_path": "main.go",
+			"edits": []interface{}{
+				map[string]interface{}{"old_string": "a", "new_string": "b"},
+				map[string]interface{}{"old_string": "c", "new_string": "d", "replace_all": true},
+			},
+		},
+	}
+	input, err = multiEdit.Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	edits := input.(*MultiEditInput).Edits
+	if len(edits) != 2 || !edits[1].ReplaceAll || edits[0].NewString != "b" {
+		t.Errorf("Unexpected edits: %+v", edits)
+	}
+
+	grep := &ToolUseBlock{
+		Name:  ToolGrep,
+		Input: map[string]interface{}{"pattern": "TODO", "-i": true, "-C": float64(2), "type": "go"},
+	}
+	input, err = grep.Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	grepInput := input.(*GrepInput)
+	if !grepInput.CaseInsensitive || grepInput.Context == nil || *grepInput.Context != 2 || grepInput.FileType != "go" {
+		t.Errorf("Unexpected grep input: %+v", grepInput)
+	}
+}
+
+func TestToolUseBlockDecodeErrors(t *testing.T) {
+	_, err := (&ToolUseBlock{Name: "mcp__github__create_issue"}).Decode()
+	if unknown, ok := err.(*UnknownToolError); !ok || unknown.Name != "mcp__github__create_issue" {
+		t.Errorf("Expected UnknownToolError, got %T: %v", err, err)
+	}
+
+	_, err = (&ToolUseBlock{Name: ToolRead, Input: map[string]interface{}{"file_path": 42}}).Decode()
+	if _, ok := err.(*CLIJSONDecodeError); !ok {
+		t.Errorf("Expected CLIJSONDecodeError for mistyped input, got %T", err)
+	}
+}
+
+func TestDecodeToolResult(t *testing.T) {
+	message := parseTestMessage(t, `{"type":"user","session_id":"s",`+
+		`"message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]},`+
+		`"tool_use_result":{"stdout":"ok\n","stderr":"","interrupted":false,"isImage":false}}`)
+
+	user := message.(*UserMessage)
+	result, err := DecodeToolResult(ToolBash, user.ToolUseResult)
+	if err != nil {
+		t.Fatalf("DecodeToolResult failed: %v", err)
+	}
+	bash, ok := result.(*BashResult)
+	if !ok || bash.Stdout != "ok\n" || bash.Interrupted {
+		t.Errorf("Unexpected bash result: %+v", result)
+	}
+
+	todos, err := DecodeToolResult(ToolTodoWrite, map[string]interface{}{
+		"oldTodos": []interface{}{},
+		"newTodos": []interface{}{map[string]interface{}{"content": "Write tests", "status": "in_progress"}},
+	})
+	if err != nil {
+		t.Fatalf("DecodeToolResult failed: %v", err)
+	}
+	newTodos := todos.(*TodoWriteResult).NewTodos
+	if len(newTodos) != 1 || newTodos[0].Status != TodoStatusInProgress {
+		t.Errorf("Unexpected todos: %+v", newTodos)
+	}
+
+	if _, err := DecodeToolResult("mcp__x__y", nil); err == nil {
+		t.Error("Expected error for unknown tool")
+	}
+}
+
+func TestToolResultBlockText(t *testing.T) {
+	tests := []struct {
+		content  interface{}
+		expected string
+	}{
+		{"plain", "plain"},
+		{nil, ""},
+		{[]interface{}{
+			map[string]interface{}{"type": "text", "text": "first"},
+			map[string]interface{}{"type": "image"},
+			map[string]interface{}{"type": "text", "text": "second"},
+		}, "first\nsecond"},
+	}
+	for _, test := range tests {
+		block := &ToolResultBlock{Content: test.content}
+		if got := block.Text(); got != test.expected {
+			t.Errorf("Text() = %q, expected %q", got, test.expected)
+		}
+	}
+}
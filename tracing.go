@@ -0,0 +1,305 @@
+package claudecode
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Span names used by TracingInterceptor
+const (
+	SpanQuery = "claude.query"
+	SpanTurn  = "claude.turn"
+	SpanTool  = "claude.tool"
+)
+
+// Tracer starts spans. It is small enough to be implemented by an adapter for
+// OpenTelemetry or another tracing library: Start corresponds to
+// trace.Tracer.Start and the slog attributes map onto span attributes.
+type Tracer interface {
+	// Start begins a span as a child of the span in ctx, if any, and returns a
+	// context carrying the new span
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+}
+
+// Span is an operation in progress
+type Span interface {
+	SetAttributes(attrs ...slog.Attr)
+	RecordError(err error)
+	End()
+}
+
+// TracingOptions configures TracingInterceptorWithOptions
+type TracingOptions struct {
+	// RecordToolInput adds a summary of each tool input (a command, file path,
+	// pattern or URL) to tool spans as the input attribute. It is off by default
+	// because the input can contain file content or secrets that would then be
+	// exported to the tracing backend.
+	RecordToolInput bool
+}
+
+// TracingInterceptor traces queries with tracer. Each query gets a claude.query
+// span, with a claude.turn span for each assistant turn (from launch or the
+// latest tool results to the next ones) and a claude.tool span for each tool
+// call, from its ToolUseBlock to the matching ToolResultBlock. Tool calls of a
+// subagent are children of the Task call that started it. The query span is
+// in the context passed on, so spans started further in nest under it.
+func TracingInterceptor(tracer Tracer) Interceptor {
+	return TracingInterceptorWithOptions(tracer, TracingOptions{})
+}
+
+// TracingInterceptorWithOptions is TracingInterceptor with options
+func TracingInterceptorWithOptions(tracer Tracer, tracingOptions TracingOptions) Interceptor {
+	return func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, prompt string, options *Options, emit func(Message) error) error {
+			attrs := []slog.Attr{slog.Int("prompt_length", len(prompt))}
+			if options != nil && options.Model != nil {
+				attrs = append(attrs, slog.String("model", *options.Model))
+			}
+			ctx, span := tracer.Start(ctx, SpanQuery, attrs...)
+			trace := &queryTrace{tracer: tracer, options: tracingOptions, ctx: ctx, span: span, tools: make(map[string]*toolTrace)}
+			trace.startTurn()
+
+			err := next(ctx, prompt, options, func(message Message) error {
+				trace.observe(message)
+				return emit(message)
+			})
+			trace.finish(err)
+			return err
+		}
+	}
+}
+
+// queryTrace tracks the open spans of one query
+type queryTrace struct {
+	tracer  Tracer
+	options TracingOptions
+	ctx     context.Context
+	span    Span
+
+	turn      Span
+	turnCtx   context.Context
+	turnIndex int
+	turnTools int
+	tools     map[string]*toolTrace
+}
+
+type toolTrace struct {
+	ctx  context.Context
+	span Span
+}
+
+func (q *queryTrace) observe(message Message) {
+	switch m := message.(type) {
+	case *SystemMessage:
+		if m.Subtype != "init" {
+			return
+		}
+		q.span.SetAttributes(slog.String("session_id", m.SessionID))
+		if m.Model != nil {
+			q.span.SetAttributes(slog.String("model", *m.Model))
+		}
+	case *AssistantMessage:
+		parent := q.turnCtx
+		if m.ParentToolUseID != nil {
+			parent = q.ctx
+			if tool := q.tools[*m.ParentToolUseID]; tool != nil {
+				parent = tool.ctx
+			}
+		}
+		for _, block := range m.ContentBlocks {
+			toolUse, ok := block.(*ToolUseBlock)
+			if !ok {
+				continue
+			}
+			if m.ParentToolUseID == nil {
+				q.turnTools++
+			}
+			attrs := []slog.Attr{slog.String("tool", toolUse.Name), slog.String("tool_use_id", toolUse.ID)}
+			if q.options.RecordToolInput {
+				attrs = append(attrs, slog.String("input", toolUse.Summary()))
+			}
+			ctx, span := q.tracer.Start(parent, SpanTool, attrs...)
+			q.tools[toolUse.ID] = &toolTrace{ctx: ctx, span: span}
+		}
+	case *UserMessage:
+		results := 0
+		for _, block := range m.ContentBlocks {
+			result, ok := block.(*ToolResultBlock)
+			if !ok {
+				continue
+			}
+			results++
+			if tool := q.tools[result.ToolUseID]; tool != nil {
+				tool.span.SetAttributes(slog.Bool("is_error", result.IsError))
+				tool.span.End()
+				delete(q.tools, result.ToolUseID)
+			}
+		}
+		if m.ParentToolUseID == nil && results > 0 {
+			q.endTurn()
+			q.startTurn()
+		}
+	case *ResultMessage:
+		q.endTurn()
+		attrs := []slog.Attr{
+			slog.String("session_id", m.SessionID),
+			slog.String("subtype", m.Subtype),
+			slog.Bool("is_error", m.IsError),
+			slog.Int("num_turns", m.NumTurns),
+			slog.Int("duration_ms", m.DurationMs),
+		}
+		if m.Usage != nil {
+			attrs = append(attrs,
+				slog.Int("input_tokens", m.Usage.InputTokens),
+				slog.Int("output_tokens", m.Usage.OutputTokens))
+		}
+		if m.TotalCostUSD != nil {
+			attrs = append(attrs, slog.Float64("cost_usd", *m.TotalCostUSD))
+		}
+		q.span.SetAttributes(attrs...)
+	}
+}
+
+// startTurn starts the span of the next turn
+func (q *queryTrace) startTurn() {
+	q.turnIndex++
+	q.turnTools = 0
+	q.turnCtx, q.turn = q.tracer.Start(q.ctx, SpanTurn, slog.Int("turn", q.turnIndex))
+}
+
+func (q *queryTrace) endTurn() {
+	if q.turn == nil {
+		return
+	}
+	q.turn.SetAttributes(slog.Int("tool_calls", q.turnTools))
+	q.turn.End()
+	q.turn = nil
+}
+
+// finish ends the spans left open, marking tool calls without a result
+func (q *queryTrace) finish(err error) {
+	for id, tool := range q.tools {
+		tool.span.SetAttributes(slog.Bool("pending", true))
+		tool.span.End()
+		delete(q.tools, id)
+	}
+	q.endTurn()
+	if err != nil {
+		q.span.RecordError(err)
+	}
+	q.span.End()
+}
+
+// RecordedSpan is a span kept by a SpanRecorder
+type RecordedSpan struct {
+	ID         int
+	ParentID   int // 0 for root spans
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]slog.Value
+	Errors     []error
+}
+
+// Attribute returns the value of an attribute, or nil when it is not set
+func (s *RecordedSpan) Attribute(key string) interface{} {
+	value, ok := s.Attributes[key]
+	if !ok {
+		return nil
+	}
+	return value.Any()
+}
+
+// SpanRecorder is a Tracer that keeps spans in memory, for tests and debugging.
+// Span IDs are unique for the life of the recorder, across Reset.
+type SpanRecorder struct {
+	mu     sync.Mutex
+	spans  []*RecordedSpan
+	nextID int
+}
+
+// NewSpanRecorder creates an empty recorder
+func NewSpanRecorder() *SpanRecorder {
+	return &SpanRecorder{}
+}
+
+type recordedSpanContextKey struct{}
+
+// Start records a new span
+func (r *SpanRecorder) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	span := &RecordedSpan{
+		ID:         r.nextID,
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: make(map[string]slog.Value),
+	}
+	if parent, ok := ctx.Value(recordedSpanContextKey{}).(*RecordedSpan); ok {
+		span.ParentID = parent.ID
+	}
+	for _, attr := range attrs {
+		span.Attributes[attr.Key] = attr.Value
+	}
+	r.spans = append(r.spans, span)
+	return context.WithValue(ctx, recordedSpanContextKey{}, span), &recorderSpan{recorder: r, span: span}
+}
+
+// Spans returns copies of the ended spans in the order they were started
+func (r *SpanRecorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var spans []RecordedSpan
+	for _, span := range r.spans {
+		if span.End.IsZero() {
+			continue
+		}
+		copied := *span
+		copied.Attributes = make(map[string]slog.Value, len(span.Attributes))
+		for key, value := range span.Attributes {
+			copied.Attributes[key] = value
+		}
+		copied.Errors = append([]error(nil), span.Errors...)
+		spans = append(spans, copied)
+	}
+	return spans
+}
+
+// Reset discards all recorded spans. Spans still open keep their IDs, which
+// are not reused.
+func (r *SpanRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
+
+// recorderSpan is the Span handle of a RecordedSpan
+type recorderSpan struct {
+	recorder *SpanRecorder
+	span     *RecordedSpan
+}
+
+func (s *recorderSpan) SetAttributes(attrs ...slog.Attr) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	for _, attr := range attrs {
+		s.span.Attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *recorderSpan) RecordError(err error) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.span.Errors = append(s.span.Errors, err)
+}
+
+func (s *recorderSpan) End() {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	if s.span.End.IsZero() {
+		s.span.End = time.Now()
+	}
+}
@@ -0,0 +1,136 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// replayInterceptor answers queries with messages and err instead of running the CLI
+func replayInterceptor(messages []Message, err error) Interceptor {
+	return func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, prompt string, options *Options, emit func(Message) error) error {
+			for _, message := range messages {
+				if emitErr := emit(message); emitErr != nil {
+					return emitErr
+				}
+			}
+			return err
+		}
+	}
+}
+
+func TestTracingInterceptor(t *testing.T) {
+	messages := subagentTranscript()
+	model := "sonnet"
+	messages[0] = &SystemMessage{Subtype: "init", SessionID: "s1", Model: &model}
+	cost := 0.5
+	messages[len(messages)-1] = &ResultMessage{Subtype: "success", SessionID: "s1", NumTurns: 2, TotalCostUSD: &cost, Usage: &Usage{InputTokens: 10, OutputTokens: 20}}
+
+	recorder := NewSpanRecorder()
+	options := &Options{Interceptors: []Interceptor{TracingInterceptor(recorder), replayInterceptor(messages, nil)}}
+	if _, err := Query(context.Background(), "Review the repo", options); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	spans := recorder.Spans()
+	expected := []struct {
+		name     string
+		parentID int
+		label    interface{}
+	}{
+		{SpanQuery, 0, nil},
+		{SpanTurn, 1, int64(1)},
+		{SpanTool, 2, ToolTask},
+		{SpanTool, 2, ToolLS},
+		{SpanTurn, 1, int64(2)},
+		{SpanTool, 3, ToolGrep},
+		{SpanTurn, 1, int64(3)},
+	}
+	if len(spans) != len(expected) {
+		t.Fatalf("Expected %d spans, got %d: %+v", len(expected), len(spans), spans)
+	}
+	for i, want := range expected {
+		span := spans[i]
+		label := span.Attribute("tool")
+		if span.Name == SpanTurn {
+			label = span.Attribute("turn")
+		}
+		if span.Name != want.name || span.ParentID != want.parentID || (want.label != nil && label != want.label) {
+			t.Errorf("Span %d: expected %s under %d (%v), got %s under %d (%v)",
+				i+1, want.name, want.parentID, want.label, span.Name, span.ParentID, label)
+		}
+		if span.End.Before(span.Start) {
+			t.Errorf("Span %d ends before it starts", i+1)
+		}
+	}
+
+	query := spans[0]
+	if query.Attribute("model") != "sonnet" || query.Attribute("session_id") != "s1" ||
+		query.Attribute("output_tokens") != int64(20) || query.Attribute("cost_usd") != 0.5 {
+		t.Errorf("Unexpected query attributes: %v", query.Attributes)
+	}
+	if spans[1].Attribute("tool_calls") != int64(2) {
+		t.Errorf("Expected 2 tool calls in the first turn, got %v", spans[1].Attribute("tool_calls"))
+	}
+	if spans[5].Attribute("is_error") != true || spans[3].Attribute("is_error") != false {
+		t.Errorf("Expected tool results to set is_error")
+	}
+}
+
+func TestTracingInterceptorError(t *testing.T) {
+	messages := []Message{
+		&AssistantMessage{ContentBlocks: []ContentBlock{&ToolUseBlock{ID: "t1", Name: ToolBash, Input: map[string]interface{}{"command": "make"}}}},
+	}
+	failure := errors.New("stalled")
+	recorder := NewSpanRecorder()
+	options := &Options{Interceptors: []Interceptor{TracingInterceptor(recorder), replayInterceptor(messages, failure)}}
+
+	stream, errs := QueryStream(context.Background(), "build", options)
+	for range stream {
+	}
+	if err := <-errs; err != failure {
+		t.Fatalf("Expected the error to pass through, got %v", err)
+	}
+
+	spans := recorder.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("Expected query, turn and tool spans, got %+v", spans)
+	}
+	if len(spans[0].Errors) != 1 || spans[0].Errors[0] != failure {
+		t.Errorf("Expected the query span to record the error, got %v", spans[0].Errors)
+	}
+	tool := spans[2]
+	if tool.Attribute("pending") != true || tool.Attribute("input") != nil {
+		t.Errorf("Expected an ended pending tool span without its input, got %v", tool.Attributes)
+	}
+
+	recorder.Reset()
+	options.Interceptors[0] = TracingInterceptorWithOptions(recorder, TracingOptions{RecordToolInput: true})
+	if _, err := Query(context.Background(), "build", options); err != failure {
+		t.Fatalf("Expected the error to pass through, got %v", err)
+	}
+	spans = recorder.Spans()
+	if len(spans) != 3 || spans[2].Attribute("input") != "make" {
+		t.Fatalf("Expected the tool input when enabled, got %+v", spans)
+	}
+	if spans[0].ID != 4 {
+		t.Errorf("Expected span IDs not to restart after Reset, got %d", spans[0].ID)
+	}
+}
+
+func TestSpanRecorderResetKeepsIDsUnique(t *testing.T) {
+	recorder := NewSpanRecorder()
+	ctx, open := recorder.Start(context.Background(), "before")
+	recorder.Reset()
+	_, fresh := recorder.Start(context.Background(), "fresh")
+	_, child := recorder.Start(ctx, "child")
+	fresh.End()
+	child.End()
+	open.End()
+
+	spans := recorder.Spans()
+	if len(spans) != 2 || spans[1].ParentID == spans[0].ID {
+		t.Errorf("Expected the child of a span from before Reset not to point at a new span, got %+v", spans)
+	}
+}
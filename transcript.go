@@ -0,0 +1,178 @@
+package claudecode
+
+// TranscriptNode is a message placed in a transcript tree
+type TranscriptNode struct {
+	Message Message
+	// Parent is the tool call whose subagent produced this message; nil for top-level messages
+	Parent *ToolCall
+	// Depth is 0 for top-level messages and grows by one per subagent level
+	Depth int
+	// ToolCalls are the tool uses made by this message, in order
+	ToolCalls []*ToolCall
+}
+
+// ToolCall pairs a ToolUseBlock with its ToolResultBlock and the subagent
+// messages the tool use spawned
+type ToolCall struct {
+	Use *ToolUseBlock
+	// Caller is the node whose message contains Use
+	Caller *TranscriptNode
+	// Result is nil while the tool call has not returned
+	Result *ToolResultBlock
+	// ResultNode is the node whose message contains Result
+	ResultNode *TranscriptNode
+	// Subagent holds the messages sent with this call's ID as ParentToolUseID
+	Subagent []*TranscriptNode
+}
+
+// IsSubagent reports whether the call started a subagent
+func (c *ToolCall) IsSubagent() bool {
+	return c.Use.Name == ToolTask || len(c.Subagent) > 0
+}
+
+// IsError reports whether the call returned an error result
+func (c *ToolCall) IsError() bool {
+	return c.Result != nil && c.Result.IsError
+}
+
+// TranscriptTree nests subagent messages under the tool calls that spawned them
+type TranscriptTree struct {
+	// Roots are the top-level messages in order
+	Roots []*TranscriptNode
+	// UnmatchedResults are tool results whose tool use is not in the transcript
+	UnmatchedResults []*ToolResultBlock
+
+	calls map[string]*ToolCall
+	order []*ToolCall
+}
+
+// BuildTranscriptTree reconstructs the conversation tree from a flat message list,
+// as returned by Query or LoadSession. Messages whose ParentToolUseID names a tool
+// use in the list are nested under that call; others stay at the top level.
+// StreamEvents are skipped.
+func BuildTranscriptTree(messages []Message) *TranscriptTree {
+	tree := &TranscriptTree{calls: make(map[string]*ToolCall)}
+
+	for _, message := range messages {
+		if _, ok := message.(*StreamEvent); ok {
+			continue
+		}
+
+		node := &TranscriptNode{Message: message}
+		if parent := tree.calls[parentToolUseID(message)]; parent != nil {
+			node.Parent = parent
+			node.Depth = parent.Caller.Depth + 1
+			parent.Subagent = append(parent.Subagent, node)
+		} else {
+			tree.Roots = append(tree.Roots, node)
+		}
+
+		for _, block := range message.Content() {
+			switch b := block.(type) {
+			case *ToolUseBlock:
+				call := &ToolCall{Use: b, Caller: node}
+				node.ToolCalls = append(node.ToolCalls, call)
+				tree.order = append(tree.order, call)
+				if b.ID != "" {
+					tree.calls[b.ID] = call
+				}
+			case *ToolResultBlock:
+				if call := tree.calls[b.ToolUseID]; call != nil && call.Result == nil {
+					call.Result = b
+					call.ResultNode = node
+				} else {
+					tree.UnmatchedResults = append(tree.UnmatchedResults, b)
+				}
+			}
+		}
+	}
+
+	return tree
+}
+
+// ToolCall returns the call with the given tool use ID, or nil
+func (t *TranscriptTree) ToolCall(id string) *ToolCall {
+	return t.calls[id]
+}
+
+// ToolCalls returns every tool call in transcript order, including subagent calls
+func (t *TranscriptTree) ToolCalls() []*ToolCall {
+	return append([]*ToolCall(nil), t.order...)
+}
+
+// PendingToolCalls returns the calls that have no result yet
+func (t *TranscriptTree) PendingToolCalls() []*ToolCall {
+	return t.FilterToolCalls(func(call *ToolCall) bool { return call.Result == nil })
+}
+
+// FilterToolCalls returns the tool calls for which keep returns true
+func (t *TranscriptTree) FilterToolCalls(keep func(call *ToolCall) bool) []*ToolCall {
+	var calls []*ToolCall
+	for _, call := range t.order {
+		if keep(call) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Walk visits the nodes depth-first: each message, then the subagent messages
+// of each of its tool calls. Returning false from visit skips the node's subagents.
+func (t *TranscriptTree) Walk(visit func(node *TranscriptNode) bool) {
+	walkNodes(t.Roots, visit)
+}
+
+func walkNodes(nodes []*TranscriptNode, visit func(node *TranscriptNode) bool) {
+	for _, node := range nodes {
+		if !visit(node) {
+			continue
+		}
+		for _, call := range node.ToolCalls {
+			walkNodes(call.Subagent, visit)
+		}
+	}
+}
+
+// Filter returns the nodes for which keep returns true, in Walk order
+func (t *TranscriptTree) Filter(keep func(node *TranscriptNode) bool) []*TranscriptNode {
+	var nodes []*TranscriptNode
+	t.Walk(func(node *TranscriptNode) bool {
+		if keep(node) {
+			nodes = append(nodes, node)
+		}
+		return true
+	})
+	return nodes
+}
+
+// Subagent returns the messages produced by the subagent of a tool call, in order,
+// including the messages of any subagents it started in turn
+func (t *TranscriptTree) Subagent(toolUseID string) []Message {
+	call := t.calls[toolUseID]
+	if call == nil {
+		return nil
+	}
+	var messages []Message
+	walkNodes(call.Subagent, func(node *TranscriptNode) bool {
+		messages = append(messages, node.Message)
+		return true
+	})
+	return messages
+}
+
+// parentToolUseID returns the ParentToolUseID of a message, or "" if it has none
+func parentToolUseID(message Message) string {
+	var parent *string
+	switch m := message.(type) {
+	case *AssistantMessage:
+		parent = m.ParentToolUseID
+	case *UserMessage:
+		parent = m.ParentToolUseID
+	case *StreamEvent:
+		parent = m.ParentToolUseID
+	}
+	if parent == nil {
+		return ""
+	}
+	return *parent
+}
@@ -0,0 +1,106 @@
+package claudecode
+
+import "testing"
+
+func subagentTranscript() []Message {
+	task := "toolu_task"
+	return []Message{
+		&SystemMessage{Subtype: "init"},
+		&UserMessage{ContentBlocks: []ContentBlock{&TextBlock{Text: "Review the repo"}}},
+		&AssistantMessage{ContentBlocks: []ContentBlock{
+			&ToolUseBlock{ID: "toolu_task", Name: ToolTask, Input: map[string]interface{}{"prompt": "Find TODOs"}},
+			&ToolUseBlock{ID: "toolu_ls", Name: ToolLS},
+		}},
+		&UserMessage{ContentBlocks: []ContentBlock{&ToolResultBlock{ToolUseID: "toolu_ls", Content: "main.go"}}},
+		&UserMessage{ParentToolUseID: &task, ContentBlocks: []ContentBlock{&TextBlock{Text: "Find TODOs"}}},
+		&AssistantMessage{ParentToolUseID: &task, ContentBlocks: []ContentBlock{
+			&ToolUseBlock{ID: "toolu_grep", Name: ToolGrep},
+		}},
+		&StreamEvent{ParentToolUseID: &task},
+		&UserMessage{ParentToolUseID: &task, ContentBlocks: []ContentBlock{
+			&ToolResultBlock{ToolUseID: "toolu_grep", Content: "no matches", IsError: true},
+		}},
+		&UserMessage{ContentBlocks: []ContentBlock{
+			&ToolResultBlock{ToolUseID: "toolu_task", Content: "No TODOs"},
+			&ToolResultBlock{ToolUseID: "toolu_unknown"},
+		}},
+		&ResultMessage{Subtype: "success"},
+	}
+}
+
+func TestBuildTranscriptTree(t *testing.T) {
+	tree := BuildTranscriptTree(subagentTranscript())
+
+	if len(tree.Roots) != 6 {
+		t.Fatalf("Expected 6 top-level messages, got %d", len(tree.Roots))
+	}
+
+	task := tree.ToolCall("toolu_task")
+	if task == nil || !task.IsSubagent() {
+		t.Fatalf("Expected Task call to be a subagent, got %+v", task)
+	}
+	if len(task.Subagent) != 3 {
+		t.Fatalf("Expected 3 subagent messages, got %d", len(task.Subagent))
+	}
+	if task.Subagent[0].Depth != 1 || task.Subagent[0].Parent != task {
+		t.Errorf("Expected subagent node at depth 1 under the Task call, got %+v", task.Subagent[0])
+	}
+	if task.Result == nil || task.Result.Content != "No TODOs" || task.ResultNode != tree.Roots[4] {
+		t.Errorf("Expected Task call paired with its result, got %+v", task.Result)
+	}
+
+	grep := tree.ToolCall("toolu_grep")
+	if grep == nil || grep.Caller.Depth != 1 || !grep.IsError() {
+		t.Errorf("Expected failed subagent Grep call, got %+v", grep)
+	}
+	if ls := tree.ToolCall("toolu_ls"); ls == nil || ls.IsSubagent() || ls.Result == nil {
+		t.Errorf("Expected LS call paired with its result, got %+v", ls)
+	}
+
+	if len(tree.UnmatchedResults) != 1 || tree.UnmatchedResults[0].ToolUseID != "toolu_unknown" {
+		t.Errorf("Expected one unmatched result, got %v", tree.UnmatchedResults)
+	}
+	if len(tree.PendingToolCalls()) != 0 {
+		t.Errorf("Expected no pending calls, got %v", tree.PendingToolCalls())
+	}
+	if len(tree.Subagent("toolu_task")) != 3 {
+		t.Errorf("Expected subagent messages for Task call")
+	}
+}
+
+func TestTranscriptTreeWalk(t *testing.T) {
+	tree := BuildTranscriptTree(subagentTranscript())
+
+	var depths []int
+	tree.Walk(func(node *TranscriptNode) bool {
+		depths = append(depths, node.Depth)
+		return true
+	})
+	expected := []int{0, 0, 0, 1, 1, 1, 0, 0, 0}
+	if len(depths) != len(expected) {
+		t.Fatalf("Expected %d nodes, got %v", len(expected), depths)
+	}
+	for i := range expected {
+		if depths[i] != expected[i] {
+			t.Fatalf("Expected depth-first order %v, got %v", expected, depths)
+		}
+	}
+
+	visited := 0
+	tree.Walk(func(node *TranscriptNode) bool {
+		visited++
+		return node.Depth == 0 && node.Message.Type() != MessageTypeAssistant
+	})
+	if visited != 6 {
+		t.Errorf("Expected subagents to be skipped, visited %d", visited)
+	}
+
+	subagentNodes := tree.Filter(func(node *TranscriptNode) bool { return node.Parent != nil })
+	if len(subagentNodes) != 3 {
+		t.Errorf("Expected 3 subagent nodes, got %d", len(subagentNodes))
+	}
+	errored := tree.FilterToolCalls((*ToolCall).IsError)
+	if len(errored) != 1 || errored[0].Use.Name != ToolGrep {
+		t.Errorf("Expected the Grep call to be the only error, got %v", errored)
+	}
+}
@@ -1,15 +1,19 @@
 package claudecode
 
-import "time"
+import (
+	"log/slog"
+	"time"
+)
 
 // MessageType represents the type of message
 type MessageType string
 
 const (
-	MessageTypeAssistant MessageType = "assistant"
-	MessageTypeUser      MessageType = "user"
-	MessageTypeSystem    MessageType = "system"
-	MessageTypeResult    MessageType = "result"
+	MessageTypeAssistant   MessageType = "assistant"
+	MessageTypeUser        MessageType = "user"
+	MessageTypeSystem      MessageType = "system"
+	MessageTypeResult      MessageType = "result"
+	MessageTypeStreamEvent MessageType = "stream_event"
 )
 
 // Message represents a message in the conversation
@@ -26,6 +30,7 @@ const (
 	ContentBlockTypeText       ContentBlockType = "text"
 	ContentBlockTypeToolUse    ContentBlockType = "tool_use"
 	ContentBlockTypeToolResult ContentBlockType = "tool_result"
+	ContentBlockTypeThinking   ContentBlockType = "thinking"
 )
 
 // ContentBlock represents a block of content within a message
@@ -64,6 +69,27 @@ func (t *ToolResultBlock) Type() ContentBlockType {
 	return ContentBlockTypeToolResult
 }
 
+// ThinkingBlock represents an extended thinking content block
+type ThinkingBlock struct {
+	Thinking  string `json:"thinking"`
+	Signature string `json:"signature,omitempty"`
+}
+
+func (t *ThinkingBlock) Type() ContentBlockType {
+	return ContentBlockTypeThinking
+}
+
+// RawBlock is a content block of a type the SDK does not model, such as image,
+// document or redacted_thinking, kept as decoded JSON
+type RawBlock struct {
+	BlockType ContentBlockType       `json:"type"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+func (t *RawBlock) Type() ContentBlockType {
+	return t.BlockType
+}
+
 // AssistantMessage represents a message from the assistant
 type AssistantMessage struct {
 	ContentBlocks   []ContentBlock `json:"content"`
@@ -89,7 +115,10 @@ type UserMessage struct {
 	ContentBlocks   []ContentBlock `json:"content"`
 	ParentToolUseID *string        `json:"parent_tool_use_id,omitempty"`
 	SessionID       string         `json:"session_id"`
-	CreatedAt       time.Time      `json:"created_at"`
+	// ToolUseResult is the structured result of a built-in tool, when reported by the CLI.
+	// Use DecodeToolResult to convert it into a typed result.
+	ToolUseResult interface{} `json:"tool_use_result,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
 }
 
 func (m *UserMessage) Type() MessageType {
@@ -170,11 +199,23 @@ const (
 	OutputFormatStreamJSON OutputFormat = "stream-json"
 )
 
-// McpServerConfig represents MCP server configuration
-type McpServerConfig struct {
-	Transport []string               `json:"transport"`
-	Env       map[string]interface{} `json:"env,omitempty"`
-}
+// InputFormat represents the input format for Claude Code queries
+type InputFormat string
+
+const (
+	InputFormatText       InputFormat = "text"
+	InputFormatStreamJSON InputFormat = "stream-json"
+)
+
+// PermissionMode represents the interaction permission level
+type PermissionMode string
+
+const (
+	PermissionModeDefault           PermissionMode = "default"
+	PermissionModeAcceptEdits       PermissionMode = "acceptEdits"
+	PermissionModeBypassPermissions PermissionMode = "bypassPermissions"
+	PermissionModePlan              PermissionMode = "plan"
+)
 
 // MCPServer represents an MCP server status
 type MCPServer struct {
@@ -219,6 +260,10 @@ type Options struct {
 	// Resume specifies a session ID to resume
 	Resume *string `json:"resume,omitempty"`
 
+	// ForkSession makes Resume or Continue start a new session branched from the
+	// original instead of appending to it. The new ID is reported by the init SystemMessage.
+	ForkSession *bool `json:"fork_session,omitempty"`
+
 	// Tool configuration
 	// AllowedTools specifies which tools Claude can use (comma or space-separated)
 	AllowedTools []string `json:"allowed_tools,omitempty"`
@@ -230,20 +275,31 @@ type Options struct {
 	// MCPTools specifies MCP tools to use
 	MCPTools []string `json:"mcp_tools,omitempty"`
 
-	// MCPServers specifies MCP server configurations
-	MCPServers map[string]McpServerConfig `json:"mcp_servers,omitempty"`
+	// MCPServers specifies MCP server configurations. They reach the CLI through a
+	// temporary file readable only by the current user, not the command line.
+	MCPServers McpServerConfigs `json:"mcp_servers,omitempty"`
+
+	// mcpServersFile is the temporary config file MCPServers were written to
+	mcpServersFile string
 
 	// MCPConfig specifies the path to MCP server configuration JSON file or JSON string
 	MCPConfig *string `json:"mcp_config,omitempty"`
 
 	// Permission and security
 	// PermissionMode defines the interaction permission level
-	// Options: "default", "acceptEdits", "bypassPermissions", "plan"
-	PermissionMode *string `json:"permission_mode,omitempty"`
+	PermissionMode *PermissionMode `json:"permission_mode,omitempty"`
 
 	// PermissionPromptTool specifies the MCP tool to use for permission prompts
 	PermissionPromptTool *string `json:"permission_prompt_tool,omitempty"`
 
+	// Approver answers permission prompts from Go. The SDK serves a permission prompt
+	// MCP server and sets MCPServers and PermissionPromptTool automatically.
+	Approver Approver `json:"-"`
+
+	// ApproverExecutable is the binary the CLI runs as the permission prompt MCP
+	// server; it must call ServeApprover. Defaults to the current executable.
+	ApproverExecutable *string `json:"-"`
+
 	// DangerouslySkipPermissions bypasses all permission checks
 	// Recommended only for sandboxes with no internet access
 	DangerouslySkipPermissions *bool `json:"dangerously_skip_permissions,omitempty"`
@@ -257,7 +313,7 @@ type Options struct {
 
 	// I/O format options
 	// InputFormat specifies the input format: "text" (default) or "stream-json"
-	InputFormat *string `json:"input_format,omitempty"`
+	InputFormat *InputFormat `json:"input_format,omitempty"`
 
 	// OutputFormat specifies the output format: "text", "json", or "stream-json"
 	OutputFormat *OutputFormat `json:"output_format,omitempty"`
@@ -269,11 +325,47 @@ type Options struct {
 	// Verbose enables verbose logging (automatically enabled for stream-json output)
 	Verbose *bool `json:"verbose,omitempty"`
 
+	// IncludePartialMessages emits StreamEvent messages with incremental deltas
+	// (requires stream-json output)
+	IncludePartialMessages *bool `json:"include_partial_messages,omitempty"`
+
+	// Stall detection
+	// IdleTimeout aborts the query with a StallError when the CLI prints nothing for this long.
+	// It requires stream-json output. Like every time.Duration, it is encoded in JSON
+	// as integer nanoseconds, e.g. "idle_timeout": 30000000000 for 30s.
+	IdleTimeout *time.Duration `json:"idle_timeout,omitempty"`
+
+	// TurnTimeout aborts the query with a StallError when a single turn
+	// (from launch or the latest tool results to the next ones) takes longer than this.
+	// It requires stream-json output and is encoded in JSON as integer nanoseconds.
+	TurnTimeout *time.Duration `json:"turn_timeout,omitempty"`
+
+	// RateLimiter throttles the start of queries and is told about their usage and
+	// rate-limit errors. Share one limiter between queries to apply a common budget.
+	RateLimiter RateLimiter `json:"-"`
+
+	// Logger receives diagnostics about launching the CLI, its output and exit.
+	// Raw output lines are logged at debug level. Nothing is logged when nil.
+	Logger *slog.Logger `json:"-"`
+
+	// RedactLog rewrites arguments, output lines and stderr before they are logged
+	// (default RedactSecrets)
+	RedactLog func(string) string `json:"-"`
+
+	// Interceptors wrap every query made with these options, the first being outermost
+	Interceptors []Interceptor `json:"-"`
+
 	// SDK-specific options
 	// AbortController allows cancellation of the query (Go context handles this)
 	// This field is not used directly but kept for API compatibility
 	AbortController interface{} `json:"abort_controller,omitempty"`
 
+	// MaxRepairAttempts bounds how often QueryJSON asks the session to fix output
+	// that does not match the requested schema (default 1, 0 disables repair).
+	// It only applies to that repair path: Query, QueryStream, RunBatch and the
+	// other entry points ignore it.
+	MaxRepairAttempts *int `json:"max_repair_attempts,omitempty"`
+
 	// Executable specifies a custom path to the Claude Code CLI
 	Executable *string `json:"executable,omitempty"`
 }
@@ -0,0 +1,139 @@
+package claudecode
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Validate checks options for invalid values and conflicting settings before
+// the CLI is launched. All problems are reported together; each is an
+// *InvalidOptionError or an *InvalidPermissionRuleError.
+func (o *Options) Validate() error {
+	var errs []error
+	invalid := func(field, reason string) {
+		errs = append(errs, &InvalidOptionError{Field: field, Reason: reason})
+	}
+
+	if o.Model != nil && strings.ContainsAny(*o.Model, " \t\r\n") {
+		invalid("Model", fmt.Sprintf("%q is not a model alias or name", *o.Model))
+	}
+	if o.MaxTurns != nil && *o.MaxTurns < 1 {
+		invalid("MaxTurns", "must be at least 1")
+	}
+	if o.MaxRepairAttempts != nil && *o.MaxRepairAttempts < 0 {
+		invalid("MaxRepairAttempts", "must not be negative")
+	}
+	if o.IdleTimeout != nil && *o.IdleTimeout < 0 {
+		invalid("IdleTimeout", "must not be negative")
+	}
+	if o.TurnTimeout != nil && *o.TurnTimeout < 0 {
+		invalid("TurnTimeout", "must not be negative")
+	}
+
+	if o.PermissionMode != nil && !o.PermissionMode.valid() {
+		invalid("PermissionMode", fmt.Sprintf("unknown permission mode %q", *o.PermissionMode))
+	}
+	if o.InputFormat != nil && !o.InputFormat.valid() {
+		invalid("InputFormat", fmt.Sprintf("unknown input format %q", *o.InputFormat))
+	}
+	if o.OutputFormat != nil && !o.OutputFormat.valid() {
+		invalid("OutputFormat", fmt.Sprintf("unknown output format %q", *o.OutputFormat))
+	}
+
+	outputFormat := OutputFormatStreamJSON
+	if o.OutputFormat != nil {
+		outputFormat = *o.OutputFormat
+	}
+	if o.InputFormat != nil && *o.InputFormat == InputFormatStreamJSON && outputFormat != OutputFormatStreamJSON {
+		invalid("InputFormat", "stream-json input requires stream-json output")
+	}
+	if o.IncludePartialMessages != nil && *o.IncludePartialMessages && outputFormat != OutputFormatStreamJSON {
+		invalid("IncludePartialMessages", "requires stream-json output")
+	}
+	// text and json output arrive all at once at the end, so there is nothing to time
+	if o.IdleTimeout != nil && *o.IdleTimeout > 0 && outputFormat != OutputFormatStreamJSON {
+		invalid("IdleTimeout", "requires stream-json output")
+	}
+	if o.TurnTimeout != nil && *o.TurnTimeout > 0 && outputFormat != OutputFormatStreamJSON {
+		invalid("TurnTimeout", "requires stream-json output")
+	}
+
+	if o.Continue != nil && *o.Continue && o.Resume != nil && *o.Resume != "" {
+		invalid("Continue", "cannot be combined with Resume")
+	}
+	if o.ForkSession != nil && *o.ForkSession && (o.Resume == nil || *o.Resume == "") && (o.Continue == nil || !*o.Continue) {
+		invalid("ForkSession", "requires Resume or Continue")
+	}
+	if o.MCPConfig != nil && *o.MCPConfig != "" && len(o.MCPServers) > 0 {
+		invalid("MCPConfig", "cannot be combined with MCPServers")
+	}
+	if o.Approver != nil && o.PermissionPromptTool != nil && *o.PermissionPromptTool != "" {
+		invalid("Approver", "cannot be combined with PermissionPromptTool")
+	}
+	for _, name := range sortedServerNames(o.MCPServers) {
+		if reason := validateMCPServer(o.MCPServers[name]); reason != "" {
+			invalid("MCPServers", fmt.Sprintf("%s: %s", name, reason))
+		}
+	}
+
+	if o.Cwd != nil && *o.Cwd != "" {
+		if reason := checkDirectory(*o.Cwd); reason != "" {
+			invalid("Cwd", reason)
+		}
+	}
+	for _, dir := range o.AddDir {
+		if reason := checkDirectory(dir); reason != "" {
+			invalid("AddDir", reason)
+		}
+	}
+
+	if _, err := parsePermissionRules(o.AllowedTools, false); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := parsePermissionRules(o.DisallowedTools, false); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateForStreaming adds the checks specific to QueryStream
+func (o *Options) validateForStreaming() error {
+	err := o.Validate()
+	if o.OutputFormat != nil && *o.OutputFormat != OutputFormatStreamJSON {
+		err = errors.Join(err, &InvalidOptionError{
+			Field:  "OutputFormat",
+			Reason: fmt.Sprintf("streaming requires stream-json output, got %q", *o.OutputFormat),
+		})
+	}
+	return err
+}
+
+func checkDirectory(dir string) string {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Sprintf("%s does not exist", dir)
+	}
+	if !info.IsDir() {
+		return fmt.Sprintf("%s is not a directory", dir)
+	}
+	return ""
+}
+
+func (m PermissionMode) valid() bool {
+	switch m {
+	case PermissionModeDefault, PermissionModeAcceptEdits, PermissionModeBypassPermissions, PermissionModePlan:
+		return true
+	}
+	return false
+}
+
+func (f InputFormat) valid() bool {
+	return f == InputFormatText || f == InputFormatStreamJSON
+}
+
+func (f OutputFormat) valid() bool {
+	return f == OutputFormatText || f == OutputFormatJSON || f == OutputFormatStreamJSON
+}
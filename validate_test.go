@@ -0,0 +1,106 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func invalidFields(err error) map[string]bool {
+	fields := map[string]bool{}
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		var optionErr *InvalidOptionError
+		if errors.As(err, &optionErr) {
+			fields[optionErr.Field] = true
+		}
+		return fields
+	}
+	for _, e := range joined.Unwrap() {
+		for field := range invalidFields(e) {
+			fields[field] = true
+		}
+	}
+	return fields
+}
+
+func TestOptionsValidate(t *testing.T) {
+	dir := t.TempDir()
+	mode := PermissionModeAcceptEdits
+	input := InputFormatText
+	if err := (&Options{Cwd: &dir, AddDir: []string{dir}, PermissionMode: &mode, InputFormat: &input}).Validate(); err != nil {
+		t.Errorf("Expected valid options, got %v", err)
+	}
+	if err := (&Options{}).Validate(); err != nil {
+		t.Errorf("Expected empty options to be valid, got %v", err)
+	}
+
+	badMode := PermissionMode("yolo")
+	badInput := InputFormat("xml")
+	streamInput := InputFormatStreamJSON
+	textOutput := OutputFormatText
+	missing := "/nonexistent/dir"
+	model := "claude sonnet"
+	options := &Options{
+		Model:                  &model,
+		MaxTurns:               intPtr(0),
+		PermissionMode:         &badMode,
+		InputFormat:            &badInput,
+		IncludePartialMessages: boolPtr(true),
+		OutputFormat:           &textOutput,
+		Continue:               boolPtr(true),
+		Resume:                 stringPtr("abc"),
+		MCPConfig:              stringPtr("mcp.json"),
+		MCPServers:             map[string]McpServerConfig{"fs": McpStdioServerConfig{}},
+		Cwd:                    &missing,
+		AddDir:                 []string{missing},
+		IdleTimeout:            durationPtr(-time.Second),
+	}
+	err := options.Validate()
+	expected := []string{
+		"Model", "MaxTurns", "PermissionMode", "InputFormat", "IncludePartialMessages",
+		"Continue", "MCPConfig", "MCPServers", "Cwd", "AddDir", "IdleTimeout",
+	}
+	fields := invalidFields(err)
+	for _, field := range expected {
+		if !fields[field] {
+			t.Errorf("Expected %s to be reported, got %v", field, err)
+		}
+	}
+
+	jsonOutput := OutputFormatJSON
+	options = &Options{OutputFormat: &jsonOutput, IdleTimeout: durationPtr(time.Second), TurnTimeout: durationPtr(time.Minute)}
+	if fields := invalidFields(options.Validate()); !fields["IdleTimeout"] || !fields["TurnTimeout"] {
+		t.Errorf("Expected timeouts with json output to be rejected, got %v", fields)
+	}
+
+	options = &Options{InputFormat: &streamInput, OutputFormat: &textOutput}
+	if !invalidFields(options.Validate())["InputFormat"] {
+		t.Error("Expected stream-json input with text output to be rejected")
+	}
+
+	var ruleErr *InvalidPermissionRuleError
+	if err := (&Options{AllowedTools: []string{"Bash(git *)"}}).Validate(); !errors.As(err, &ruleErr) {
+		t.Errorf("Expected permission rule error, got %v", err)
+	}
+}
+
+func TestQueryValidatesBeforeLaunch(t *testing.T) {
+	cli := writeFakeCLI(t, "echo launched >&2; exit 1\n")
+	mode := PermissionMode("auto")
+	_, err := Query(context.Background(), "hi", &Options{Executable: &cli, PermissionMode: &mode})
+	var optionErr *InvalidOptionError
+	if !errors.As(err, &optionErr) || optionErr.Field != "PermissionMode" {
+		t.Errorf("Expected InvalidOptionError for PermissionMode, got %T: %v", err, err)
+	}
+
+	textOutput := OutputFormatText
+	messageChan, errorChan := QueryStream(context.Background(), "hi", &Options{Executable: &cli, OutputFormat: &textOutput})
+	for range messageChan {
+		t.Error("Expected no messages")
+	}
+	if err := <-errorChan; !errors.As(err, &optionErr) || optionErr.Field != "OutputFormat" {
+		t.Errorf("Expected InvalidOptionError for OutputFormat, got %T: %v", err, err)
+	}
+}